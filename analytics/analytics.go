@@ -0,0 +1,278 @@
+// Package analytics computes radio and link quality statistics from messages
+package analytics
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// PoorHealth is the health score below which a link is considered poor
+const PoorHealth = 40
+
+// snrFloor is the demodulation SNR limit of each spreading factor, in dB
+var snrFloor = map[int]float64{
+	7:  -7.5,
+	8:  -10,
+	9:  -12.5,
+	10: -15,
+	11: -17.5,
+	12: -20,
+}
+
+// healthyMargin is the SNR margin, in dB, that scores a link 100
+const healthyMargin = 10.0
+
+// LinkStats are the statistics of the uplinks of a device or a gateway
+type LinkStats struct {
+	Messages      int     `json:"messages"`
+	Duplicates    int     `json:"duplicates"`
+	DuplicateRate float64 `json:"duplicate_rate"`
+
+	RSSI Summary `json:"rssi"`
+	SNR  Summary `json:"snr"`
+
+	// Margin is the SNR above the demodulation floor of the spreading factor
+	Margin Summary `json:"margin"`
+
+	SpreadingFactors map[int]int `json:"spreading_factors"`
+	Channels         map[int]int `json:"channels"`
+
+	// Frequencies counts the messages per frequency in kHz
+	Frequencies map[int]int `json:"frequencies"`
+
+	// Health scores the link budget from 0 to 100 using the 10th percentile
+	// of the SNR margin, so occasional bad packets already lower it
+	Health float64 `json:"health"`
+
+	// HealthKnown is false when no message had a known spreading factor,
+	// e.g. connections pushing messages without radio parameters
+	HealthKnown bool `json:"health_known"`
+}
+
+// Poor reports whether the link health is known and below PoorHealth
+func (s LinkStats) Poor() bool {
+	return s.HealthKnown && s.Health < PoorHealth
+}
+
+type accumulator struct {
+	messages   int
+	duplicates int
+	rssi       samples
+	snr        samples
+	margin     samples
+	sf         map[int]int
+	channels   map[int]int
+	freqs      map[int]int
+}
+
+func newAccumulator() *accumulator {
+	return &accumulator{
+		sf:       map[int]int{},
+		channels: map[int]int{},
+		freqs:    map[int]int{},
+	}
+}
+
+func (a *accumulator) add(msg nlttypes.Message) {
+	radio := msg.Params.Radio
+
+	a.messages++
+	if msg.Params.Duplicate {
+		a.duplicates++
+	}
+
+	// connections may push messages without radio parameters, their zero
+	// values would skew the statistics
+	sf := radio.Modulation.Spreading
+	if sf == 0 && radio.Freq == 0 {
+		return
+	}
+
+	a.rssi = append(a.rssi, float64(radio.Hardware.Rssi))
+	a.snr = append(a.snr, radio.Hardware.Snr)
+	a.channels[radio.Hardware.Channel]++
+
+	if sf != 0 {
+		a.sf[sf]++
+	}
+
+	if radio.Freq != 0 {
+		a.freqs[int(math.Round(radio.Freq*1000))]++
+	}
+
+	if floor, ok := snrFloor[sf]; ok {
+		a.margin = append(a.margin, radio.Hardware.Snr-floor)
+	}
+}
+
+func (a *accumulator) stats() LinkStats {
+	stats := LinkStats{
+		Messages:         a.messages,
+		Duplicates:       a.duplicates,
+		RSSI:             a.rssi.summary(),
+		SNR:              a.snr.summary(),
+		Margin:           a.margin.summary(),
+		SpreadingFactors: copyCounts(a.sf),
+		Channels:         copyCounts(a.channels),
+		Frequencies:      copyCounts(a.freqs),
+	}
+
+	if a.messages > 0 {
+		stats.DuplicateRate = float64(a.duplicates) / float64(a.messages)
+	}
+
+	if stats.Margin.Count > 0 {
+		stats.Health = clamp(stats.Margin.P10/healthyMargin*100, 0, 100)
+		stats.HealthKnown = true
+	}
+
+	return stats
+}
+
+// Collector accumulates uplink messages per device and per gateway.
+// It is not safe for concurrent use.
+type Collector struct {
+	devices  map[string]*accumulator
+	gateways map[string]*accumulator
+}
+
+// NewCollector creates an empty collector
+func NewCollector() *Collector {
+	return &Collector{
+		devices:  map[string]*accumulator{},
+		gateways: map[string]*accumulator{},
+	}
+}
+
+// FromMessages collects a slice of messages
+func FromMessages(messages []nlttypes.Message) *Collector {
+	c := NewCollector()
+
+	for _, msg := range messages {
+		c.Add(msg)
+	}
+
+	return c
+}
+
+// FromStream collects the messages of the channel until it is closed or the
+// context is canceled
+func FromStream(ctx context.Context, messages <-chan nlttypes.Message) *Collector {
+	c := NewCollector()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return c
+		case msg, ok := <-messages:
+			if !ok {
+				return c
+			}
+
+			c.Add(msg)
+		}
+	}
+}
+
+// Add collects a message, messages other than uplinks are ignored
+func (c *Collector) Add(msg nlttypes.Message) {
+	if msg.Type != "" && msg.Type != nlttypes.MessageUplink {
+		return
+	}
+
+	accumulate(c.devices, msg.Meta.Device, msg)
+	accumulate(c.gateways, msg.Meta.Gateway, msg)
+}
+
+// Device returns the statistics of a device
+func (c *Collector) Device(devEui string) (LinkStats, bool) {
+	a, ok := c.devices[devEui]
+	if !ok {
+		return LinkStats{}, false
+	}
+
+	return a.stats(), true
+}
+
+// Devices returns the statistics of every device
+func (c *Collector) Devices() map[string]LinkStats {
+	return statsOf(c.devices)
+}
+
+// Gateways returns the statistics of every gateway
+func (c *Collector) Gateways() map[string]LinkStats {
+	return statsOf(c.gateways)
+}
+
+// PoorDevices returns the devices with poor link health, worst first.
+// Devices whose health is unknown are not listed.
+func (c *Collector) PoorDevices() []string {
+	stats := c.Devices()
+
+	var poor []string
+	for devEui, s := range stats {
+		if s.Poor() {
+			poor = append(poor, devEui)
+		}
+	}
+
+	sort.Slice(poor, func(i, j int) bool {
+		hi, hj := stats[poor[i]].Health, stats[poor[j]].Health
+		if hi != hj {
+			return hi < hj
+		}
+
+		return poor[i] < poor[j]
+	})
+
+	return poor
+}
+
+func accumulate(accs map[string]*accumulator, key string, msg nlttypes.Message) {
+	if key == "" {
+		return
+	}
+
+	a, ok := accs[key]
+	if !ok {
+		a = newAccumulator()
+		accs[key] = a
+	}
+
+	a.add(msg)
+}
+
+func statsOf(accs map[string]*accumulator) map[string]LinkStats {
+	stats := make(map[string]LinkStats, len(accs))
+
+	for key, a := range accs {
+		stats[key] = a.stats()
+	}
+
+	return stats
+}
+
+func copyCounts[K comparable](counts map[K]int) map[K]int {
+	out := make(map[K]int, len(counts))
+
+	for k, v := range counts {
+		out[k] = v
+	}
+
+	return out
+}
+
+func clamp(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+
+	if v > hi {
+		return hi
+	}
+
+	return v
+}
@@ -0,0 +1,168 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+func uplink(device, gateway string, sf int, snr float64, rssi int, duplicate bool) nlttypes.Message {
+	var msg nlttypes.Message
+
+	msg.Type = nlttypes.MessageUplink
+	msg.Meta.Device = device
+	msg.Meta.Gateway = gateway
+	msg.Params.Duplicate = duplicate
+	msg.Params.Radio.Modulation.Spreading = sf
+	msg.Params.Radio.Hardware.Snr = snr
+	msg.Params.Radio.Hardware.Rssi = rssi
+
+	return msg
+}
+
+func TestCollector(t *testing.T) {
+	var status nlttypes.Message
+	status.Type = nlttypes.MessageStatus
+	status.Meta.Device = "d1"
+
+	c := FromMessages([]nlttypes.Message{
+		uplink("d1", "g1", 7, 5, -90, false),
+		uplink("d1", "g2", 7, 7.5, -100, true),
+		uplink("d2", "g1", 12, -19, -125, false),
+		status,
+	})
+
+	d1, ok := c.Device("d1")
+	if !ok {
+		t.Fatal("device d1 missing")
+	}
+
+	if d1.Messages != 2 || d1.Duplicates != 1 || d1.DuplicateRate != 0.5 {
+		t.Errorf("d1 counts = %d messages, %d duplicates, rate %v", d1.Messages, d1.Duplicates, d1.DuplicateRate)
+	}
+
+	if d1.RSSI.Min != -100 || d1.RSSI.Max != -90 || d1.RSSI.P50 != -95 {
+		t.Errorf("d1 rssi = %+v", d1.RSSI)
+	}
+
+	if !reflect.DeepEqual(d1.SpreadingFactors, map[int]int{7: 2}) {
+		t.Errorf("d1 spreading factors = %v", d1.SpreadingFactors)
+	}
+
+	if !d1.HealthKnown || d1.Health != 100 || d1.Poor() {
+		t.Errorf("d1 health = %v known %v", d1.Health, d1.HealthKnown)
+	}
+
+	if g1 := c.Gateways()["g1"]; g1.Messages != 2 {
+		t.Errorf("g1 messages = %d, want 2", g1.Messages)
+	}
+
+	if poor := c.PoorDevices(); !reflect.DeepEqual(poor, []string{"d2"}) {
+		t.Errorf("poor devices = %v, want [d2]", poor)
+	}
+}
+
+func TestUnknownSpreadingFactorIsNotPoor(t *testing.T) {
+	c := FromMessages([]nlttypes.Message{uplink("d1", "g1", 0, 9, -80, false)})
+
+	d1, _ := c.Device("d1")
+	if d1.HealthKnown {
+		t.Errorf("health known without spreading factor: %v", d1.Health)
+	}
+
+	if d1.Poor() {
+		t.Error("device without spreading factor reported poor")
+	}
+
+	if poor := c.PoorDevices(); len(poor) != 0 {
+		t.Errorf("poor devices = %v, want none", poor)
+	}
+}
+
+func TestMessagesWithoutRadio(t *testing.T) {
+	withFreq := uplink("d1", "g1", 9, -3, -105, false)
+	withFreq.Params.Radio.Freq = 917.2
+	withFreq.Params.Radio.Hardware.Channel = 2
+
+	c := FromMessages([]nlttypes.Message{
+		withFreq,
+		uplink("d1", "g1", 0, 0, 0, false),
+		uplink("d1", "g1", 0, 0, 0, true),
+	})
+
+	d1, _ := c.Device("d1")
+
+	if d1.Messages != 3 || d1.Duplicates != 1 {
+		t.Errorf("d1 counts = %d messages, %d duplicates", d1.Messages, d1.Duplicates)
+	}
+
+	if d1.RSSI.Count != 1 || d1.RSSI.Max != -105 || d1.SNR.Count != 1 || d1.SNR.Max != -3 {
+		t.Errorf("d1 rssi = %+v, snr = %+v", d1.RSSI, d1.SNR)
+	}
+
+	if !reflect.DeepEqual(d1.SpreadingFactors, map[int]int{9: 1}) {
+		t.Errorf("d1 spreading factors = %v", d1.SpreadingFactors)
+	}
+
+	if !reflect.DeepEqual(d1.Channels, map[int]int{2: 1}) {
+		t.Errorf("d1 channels = %v", d1.Channels)
+	}
+
+	if !reflect.DeepEqual(d1.Frequencies, map[int]int{917200: 1}) {
+		t.Errorf("d1 frequencies = %v", d1.Frequencies)
+	}
+
+	if d1.Margin.Count != 1 || !d1.HealthKnown || d1.Health != 95 {
+		t.Errorf("d1 margin = %+v, health = %v known %v", d1.Margin, d1.Health, d1.HealthKnown)
+	}
+}
+
+func TestLinkStatsJSON(t *testing.T) {
+	msg := uplink("d1", "g1", 7, 5, -90, false)
+	msg.Params.Radio.Freq = 916.8
+	msg.Params.Radio.Hardware.Channel = 3
+
+	d1, _ := FromMessages([]nlttypes.Message{msg}).Device("d1")
+
+	data, err := json.Marshal(d1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got LinkStats
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, d1) {
+		t.Errorf("round trip = %+v, want %+v", got, d1)
+	}
+
+	if !reflect.DeepEqual(got.Frequencies, map[int]int{916800: 1}) {
+		t.Errorf("frequencies = %v, want 916800 kHz", got.Frequencies)
+	}
+}
+
+func TestFromStream(t *testing.T) {
+	messages := make(chan nlttypes.Message, 3)
+	messages <- uplink("d1", "g1", 9, 0, -110, false)
+	messages <- uplink("d1", "g1", 9, 1, -111, false)
+	close(messages)
+
+	c := FromStream(context.Background(), messages)
+
+	if d1, _ := c.Device("d1"); d1.Messages != 2 {
+		t.Errorf("d1 messages = %d, want 2", d1.Messages)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	s := samples{4, 1, 3, 2, 5}.summary()
+
+	if s.Min != 1 || s.Max != 5 || s.Mean != 3 || s.P50 != 3 || s.P10 != 1.4 {
+		t.Errorf("summary = %+v", s)
+	}
+}
@@ -0,0 +1,60 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary describes a series of samples
+type Summary struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	P10   float64 `json:"p10"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+}
+
+// samples accumulates values to summarize
+type samples []float64
+
+func (s samples) summary() Summary {
+	if len(s) == 0 {
+		return Summary{}
+	}
+
+	sorted := make([]float64, len(s))
+	copy(sorted, s)
+	sort.Float64s(sorted)
+
+	sum := 0.0
+	for _, v := range sorted {
+		sum += v
+	}
+
+	return Summary{
+		Count: len(sorted),
+		Min:   sorted[0],
+		Max:   sorted[len(sorted)-1],
+		Mean:  sum / float64(len(sorted)),
+		P10:   percentile(sorted, 10),
+		P50:   percentile(sorted, 50),
+		P90:   percentile(sorted, 90),
+		P95:   percentile(sorted, 95),
+	}
+}
+
+// percentile interpolates the p-th percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 1 {
+		return sorted[0]
+	}
+
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
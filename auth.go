@@ -3,6 +3,8 @@ package gonlt
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"net/http"
 
 	"github.com/douglaszuqueto/gonlt/credentials"
 	"github.com/douglaszuqueto/gonlt/nlttypes"
@@ -14,33 +16,44 @@ type AuthService interface {
 }
 
 type AuthServiceOp struct {
-	rest  krest.Client
-	creds *credentials.Credentials
+	rest   krest.Client
+	tokens *credentials.TokenStore
+	cfg    *config
 }
 
 var _ AuthService = &AuthServiceOp{}
 
-func NewAuthService(rest krest.Client, creds *credentials.Credentials) AuthServiceOp {
+func NewAuthService(rest krest.Client, tokens *credentials.TokenStore, opts ...Option) AuthServiceOp {
+	return newAuthService(rest, tokens, newConfig(opts...))
+}
+
+// newAuthService creates the service sharing the config of a Client
+func newAuthService(rest krest.Client, tokens *credentials.TokenStore, cfg *config) AuthServiceOp {
 	return AuthServiceOp{
-		rest:  rest,
-		creds: creds,
+		rest:   rest,
+		tokens: tokens,
+		cfg:    cfg,
 	}
 }
 
 func (s AuthServiceOp) Login(ctx context.Context, email, password string) (*nlttypes.AuthResponse, error) {
-	endpoint := buildEndpoint("token")
+	endpoint := s.cfg.endpoint("token")
 
 	resp, err := s.rest.Post(ctx, endpoint, krest.RequestData{
 		Headers:    map[string]string{},
-		MaxRetries: 3,
+		MaxRetries: s.cfg.maxRetries,
 		Body: map[string]interface{}{
 			"email":    email,
 			"password": password,
 		},
 	})
 	if err != nil {
-		if resp.StatusCode == 400 {
-			return nil, handleAuthError(resp.Body)
+		err = handleError(http.MethodPost, endpoint, resp, err)
+
+		// the token endpoint answers bad credentials with a 400
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest {
+			apiErr.kind = ErrUnauthorized
 		}
 
 		return nil, err
@@ -53,7 +66,7 @@ func (s AuthServiceOp) Login(ctx context.Context, email, password string) (*nltt
 		return nil, err
 	}
 
-	s.creds.Token = account.AccessToken
+	s.tokens.SetToken(account.AccessToken)
 
 	return &account, nil
 }
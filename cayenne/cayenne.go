@@ -0,0 +1,348 @@
+// Package cayenne implements the Cayenne Low Power Payload format
+package cayenne
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Type is the LPP data type, the IPSO object id minus 3200
+type Type uint8
+
+const (
+	DigitalInput  Type = 0
+	DigitalOutput Type = 1
+	AnalogInput   Type = 2
+	AnalogOutput  Type = 3
+	GenericSensor Type = 100
+	Illuminance   Type = 101
+	Presence      Type = 102
+	Temperature   Type = 103
+	Humidity      Type = 104
+	Accelerometer Type = 113
+	Barometer     Type = 115
+	Voltage       Type = 116
+	Current       Type = 117
+	Frequency     Type = 118
+	Percentage    Type = 120
+	Altitude      Type = 121
+	Concentration Type = 125
+	Power         Type = 128
+	Distance      Type = 130
+	Energy        Type = 131
+	Direction     Type = 132
+	UnixTime      Type = 133
+	Gyrometer     Type = 134
+	Colour        Type = 135
+	GPS           Type = 136
+	Switch        Type = 142
+)
+
+var (
+	ErrUnknownType = errors.New("cayenne: unknown type")
+	ErrTruncated   = errors.New("cayenne: truncated payload")
+	ErrOutOfRange  = errors.New("cayenne: value out of range")
+)
+
+// kind tells how the value of a type is stored
+type kind int
+
+const (
+	kindScalar kind = iota
+	kindVector
+	kindGPS
+	kindColour
+)
+
+type typeInfo struct {
+	name string
+	kind kind
+
+	// size of each scalar component in bytes
+	size       int
+	signed     bool
+	resolution float64
+}
+
+var types = map[Type]typeInfo{
+	DigitalInput:  {name: "digital_input", size: 1, resolution: 1},
+	DigitalOutput: {name: "digital_output", size: 1, resolution: 1},
+	AnalogInput:   {name: "analog_input", size: 2, signed: true, resolution: 0.01},
+	AnalogOutput:  {name: "analog_output", size: 2, signed: true, resolution: 0.01},
+	GenericSensor: {name: "generic_sensor", size: 4, resolution: 1},
+	Illuminance:   {name: "illuminance", size: 2, resolution: 1},
+	Presence:      {name: "presence", size: 1, resolution: 1},
+	Temperature:   {name: "temperature", size: 2, signed: true, resolution: 0.1},
+	Humidity:      {name: "humidity", size: 1, resolution: 0.5},
+	Accelerometer: {name: "accelerometer", kind: kindVector, size: 2, signed: true, resolution: 0.001},
+	Barometer:     {name: "barometer", size: 2, resolution: 0.1},
+	Voltage:       {name: "voltage", size: 2, resolution: 0.01},
+	Current:       {name: "current", size: 2, resolution: 0.001},
+	Frequency:     {name: "frequency", size: 4, resolution: 1},
+	Percentage:    {name: "percentage", size: 1, resolution: 1},
+	Altitude:      {name: "altitude", size: 2, signed: true, resolution: 1},
+	Concentration: {name: "concentration", size: 2, resolution: 1},
+	Power:         {name: "power", size: 2, resolution: 1},
+	Distance:      {name: "distance", size: 4, resolution: 0.001},
+	Energy:        {name: "energy", size: 4, resolution: 0.001},
+	Direction:     {name: "direction", size: 2, resolution: 1},
+	UnixTime:      {name: "unix_time", size: 4, resolution: 1},
+	Gyrometer:     {name: "gyrometer", kind: kindVector, size: 2, signed: true, resolution: 0.01},
+	Colour:        {name: "colour", kind: kindColour, size: 1, resolution: 1},
+	GPS:           {name: "gps", kind: kindGPS, size: 3, signed: true},
+	Switch:        {name: "switch", size: 1, resolution: 1},
+}
+
+// gps resolutions of latitude/longitude and altitude
+const (
+	gpsCoordResolution    = 0.0001
+	gpsAltitudeResolution = 0.01
+)
+
+func (t Type) String() string {
+	if info, ok := types[t]; ok {
+		return info.name
+	}
+
+	return fmt.Sprintf("type(%d)", uint8(t))
+}
+
+// Size returns the size of the data of the type, without channel and type bytes
+func (t Type) Size() int {
+	info, ok := types[t]
+	if !ok {
+		return 0
+	}
+
+	switch info.kind {
+	case kindVector, kindGPS, kindColour:
+		return info.size * 3
+	default:
+		return info.size
+	}
+}
+
+// Vector is a 3 axis value of accelerometers and gyrometers
+type Vector struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Location is a GPS position, altitude in meters
+type Location struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Altitude  float64 `json:"altitude"`
+}
+
+// RGB colour
+type RGB struct {
+	R uint8 `json:"r"`
+	G uint8 `json:"g"`
+	B uint8 `json:"b"`
+}
+
+// Reading is a value of a channel, only the field matching the type is set
+type Reading struct {
+	Channel uint8 `json:"channel"`
+	Type    Type  `json:"type"`
+
+	Value    float64   `json:"value"`
+	Vector   *Vector   `json:"vector,omitempty"`
+	Location *Location `json:"location,omitempty"`
+	Colour   *RGB      `json:"colour,omitempty"`
+}
+
+// Payload is a sequence of readings
+type Payload []Reading
+
+// Find returns the reading of the channel and type
+func (p Payload) Find(channel uint8, typ Type) (Reading, bool) {
+	for _, r := range p {
+		if r.Channel == channel && r.Type == typ {
+			return r, true
+		}
+	}
+
+	return Reading{}, false
+}
+
+// Channels returns the channels present in the payload, sorted
+func (p Payload) Channels() []uint8 {
+	seen := map[uint8]bool{}
+
+	var channels []uint8
+	for _, r := range p {
+		if !seen[r.Channel] {
+			seen[r.Channel] = true
+			channels = append(channels, r.Channel)
+		}
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i] < channels[j]
+	})
+
+	return channels
+}
+
+// Decode parses a LPP payload
+func Decode(data []byte) (Payload, error) {
+	var payload Payload
+
+	for pos := 0; pos < len(data); {
+		if pos+2 > len(data) {
+			return nil, fmt.Errorf("%w: header at byte %d", ErrTruncated, pos)
+		}
+
+		r := Reading{
+			Channel: data[pos],
+			Type:    Type(data[pos+1]),
+		}
+
+		info, ok := types[r.Type]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d at byte %d", ErrUnknownType, uint8(r.Type), pos+1)
+		}
+
+		pos += 2
+
+		size := r.Type.Size()
+		if pos+size > len(data) {
+			return nil, fmt.Errorf("%w: %s on channel %d needs %d bytes, got %d", ErrTruncated, r.Type, r.Channel, size, len(data)-pos)
+		}
+
+		raw := data[pos : pos+size]
+		pos += size
+
+		switch info.kind {
+		case kindScalar:
+			r.Value = fromRaw(readInt(raw, info.signed), info.resolution)
+		case kindVector:
+			r.Vector = &Vector{
+				X: fromRaw(readInt(raw[0:2], true), info.resolution),
+				Y: fromRaw(readInt(raw[2:4], true), info.resolution),
+				Z: fromRaw(readInt(raw[4:6], true), info.resolution),
+			}
+		case kindGPS:
+			r.Location = &Location{
+				Latitude:  fromRaw(readInt(raw[0:3], true), gpsCoordResolution),
+				Longitude: fromRaw(readInt(raw[3:6], true), gpsCoordResolution),
+				Altitude:  fromRaw(readInt(raw[6:9], true), gpsAltitudeResolution),
+			}
+		case kindColour:
+			r.Colour = &RGB{R: raw[0], G: raw[1], B: raw[2]}
+		}
+
+		payload = append(payload, r)
+	}
+
+	return payload, nil
+}
+
+// Encode serializes the payload
+func (p Payload) Encode() ([]byte, error) {
+	var data []byte
+
+	for _, r := range p {
+		info, ok := types[r.Type]
+		if !ok {
+			return nil, fmt.Errorf("%w: %d on channel %d", ErrUnknownType, uint8(r.Type), r.Channel)
+		}
+
+		data = append(data, r.Channel, byte(r.Type))
+
+		var err error
+
+		switch info.kind {
+		case kindScalar:
+			data, err = appendValue(data, r.Value, info.resolution, info.size, info.signed)
+		case kindVector:
+			v := Vector{}
+			if r.Vector != nil {
+				v = *r.Vector
+			}
+
+			for _, c := range []float64{v.X, v.Y, v.Z} {
+				if data, err = appendValue(data, c, info.resolution, info.size, true); err != nil {
+					break
+				}
+			}
+		case kindGPS:
+			l := Location{}
+			if r.Location != nil {
+				l = *r.Location
+			}
+
+			data, err = appendValue(data, l.Latitude, gpsCoordResolution, 3, true)
+			if err == nil {
+				data, err = appendValue(data, l.Longitude, gpsCoordResolution, 3, true)
+			}
+
+			if err == nil {
+				data, err = appendValue(data, l.Altitude, gpsAltitudeResolution, 3, true)
+			}
+		case kindColour:
+			c := RGB{}
+			if r.Colour != nil {
+				c = *r.Colour
+			}
+
+			data = append(data, c.R, c.G, c.B)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("%s on channel %d: %w", r.Type, r.Channel, err)
+		}
+	}
+
+	return data, nil
+}
+
+// fromRaw scales a raw value, dividing by the inverse of the resolution
+// keeps values like 27.2 exact
+func fromRaw(n int64, resolution float64) float64 {
+	return float64(n) / math.Round(1/resolution)
+}
+
+func readInt(raw []byte, signed bool) int64 {
+	var v uint64
+	for _, b := range raw {
+		v = v<<8 | uint64(b)
+	}
+
+	if !signed {
+		return int64(v)
+	}
+
+	bits := uint(len(raw) * 8)
+
+	return int64(v<<(64-bits)) >> (64 - bits)
+}
+
+// appendValue appends value/resolution rounded to size big endian bytes
+func appendValue(data []byte, value, resolution float64, size int, signed bool) ([]byte, error) {
+	n := math.Round(value / resolution)
+	bits := uint(size * 8)
+
+	var lo, hi float64
+	if signed {
+		lo, hi = -math.Ldexp(1, int(bits)-1), math.Ldexp(1, int(bits)-1)-1
+	} else {
+		lo, hi = 0, math.Ldexp(1, int(bits))-1
+	}
+
+	if math.IsNaN(n) || n < lo || n > hi {
+		return data, fmt.Errorf("%w: %v", ErrOutOfRange, value)
+	}
+
+	v := uint64(int64(n))
+	for i := size - 1; i >= 0; i-- {
+		data = append(data, byte(v>>(uint(i)*8)))
+	}
+
+	return data, nil
+}
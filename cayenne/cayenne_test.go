@@ -0,0 +1,176 @@
+package cayenne
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+// sampleReading returns an in range reading of the type
+func sampleReading(channel uint8, typ Type) Reading {
+	info := types[typ]
+	r := Reading{Channel: channel, Type: typ}
+
+	switch info.kind {
+	case kindScalar:
+		r.Value = 123 * info.resolution
+		if info.signed {
+			r.Value = -r.Value
+		}
+	case kindVector:
+		r.Vector = &Vector{X: 12 * info.resolution, Y: -34 * info.resolution, Z: 56 * info.resolution}
+	case kindGPS:
+		r.Location = &Location{Latitude: 42.3519, Longitude: -87.9094, Altitude: 10}
+	case kindColour:
+		r.Colour = &RGB{R: 1, G: 128, B: 255}
+	}
+
+	return r
+}
+
+func TestRoundTripEveryType(t *testing.T) {
+	for typ := range types {
+		t.Run(typ.String(), func(t *testing.T) {
+			want := sampleReading(7, typ)
+
+			data, err := Payload{want}.Encode()
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+
+			if len(data) != 2+typ.Size() {
+				t.Fatalf("encoded %d bytes, want %d", len(data), 2+typ.Size())
+			}
+
+			payload, err := Decode(data)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+
+			if len(payload) != 1 {
+				t.Fatalf("decoded %d readings, want 1", len(payload))
+			}
+
+			got := payload[0]
+			if got.Channel != want.Channel || got.Type != want.Type {
+				t.Fatalf("got channel %d type %s, want channel %d type %s", got.Channel, got.Type, want.Channel, want.Type)
+			}
+
+			switch {
+			case want.Vector != nil:
+				if got.Vector == nil || !almostEqual(got.Vector.X, want.Vector.X) ||
+					!almostEqual(got.Vector.Y, want.Vector.Y) || !almostEqual(got.Vector.Z, want.Vector.Z) {
+					t.Errorf("got vector %+v, want %+v", got.Vector, want.Vector)
+				}
+			case want.Location != nil:
+				if got.Location == nil || !almostEqual(got.Location.Latitude, want.Location.Latitude) ||
+					!almostEqual(got.Location.Longitude, want.Location.Longitude) ||
+					!almostEqual(got.Location.Altitude, want.Location.Altitude) {
+					t.Errorf("got location %+v, want %+v", got.Location, want.Location)
+				}
+			case want.Colour != nil:
+				if got.Colour == nil || *got.Colour != *want.Colour {
+					t.Errorf("got colour %+v, want %+v", got.Colour, want.Colour)
+				}
+			default:
+				if !almostEqual(got.Value, want.Value) {
+					t.Errorf("got value %v, want %v", got.Value, want.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeReferenceVector(t *testing.T) {
+	payload, err := Decode([]byte{0x03, 0x67, 0x01, 0x10, 0x05, 0x67, 0x00, 0xFF})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []Reading{
+		{Channel: 3, Type: Temperature, Value: 27.2},
+		{Channel: 5, Type: Temperature, Value: 25.5},
+	}
+
+	if len(payload) != len(want) {
+		t.Fatalf("decoded %d readings, want %d", len(payload), len(want))
+	}
+
+	for i, r := range want {
+		if payload[i].Channel != r.Channel || payload[i].Type != r.Type || payload[i].Value != r.Value {
+			t.Errorf("reading %d = %+v, want %+v", i, payload[i], r)
+		}
+	}
+
+	data, err := payload.Encode()
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	if !bytes.Equal(data, []byte{0x03, 0x67, 0x01, 0x10, 0x05, 0x67, 0x00, 0xFF}) {
+		t.Errorf("encoded % X", data)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"header", []byte{0x01}, ErrTruncated},
+		{"value", []byte{0x01, 0x67, 0x01}, ErrTruncated},
+		{"gps", []byte{0x01, 0x88, 0x00, 0x00, 0x00}, ErrTruncated},
+		{"unknown type", []byte{0x01, 0xFE, 0x00}, ErrUnknownType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Decode(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload Payload
+		want    error
+	}{
+		{"unknown type", Payload{{Channel: 1, Type: Type(254)}}, ErrUnknownType},
+		{"unsigned negative", *new(Payload).AddHumidity(1, -1), ErrOutOfRange},
+		{"unsigned overflow", *new(Payload).AddDigitalInput(1, 0).Add(2, Percentage, 256), ErrOutOfRange},
+		{"signed overflow", *new(Payload).AddTemperature(1, 3276.8), ErrOutOfRange},
+		{"vector overflow", *new(Payload).AddAccelerometer(1, 0, 40, 0), ErrOutOfRange},
+		{"gps overflow", *new(Payload).AddGPS(1, 900, 0, 0), ErrOutOfRange},
+		{"not a number", *new(Payload).AddAnalogInput(1, math.NaN()), ErrOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.payload.Encode(); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestZeroValueInJSON(t *testing.T) {
+	data, err := json.Marshal(Reading{Channel: 1, Type: DigitalInput, Value: 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), `"value":0`) {
+		t.Errorf("zero value missing from %s", data)
+	}
+}
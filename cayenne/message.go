@@ -0,0 +1,111 @@
+package cayenne
+
+import (
+	"github.com/douglaszuqueto/gonlt"
+	"github.com/douglaszuqueto/gonlt/decoder"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// Decoder decodes LPP payloads, register it in a decoder.Registry
+var Decoder decoder.Decoder = decoder.DecoderFunc(func(payload []byte, port int) (interface{}, error) {
+	return Decode(payload)
+})
+
+// DecodeMessage decodes the base64 payload of an uplink message
+func DecodeMessage(msg nlttypes.Message) (Payload, error) {
+	data, err := gonlt.DecodePayload(msg.Params.Payload, gonlt.PayloadBase64)
+	if err != nil {
+		return nil, err
+	}
+
+	return Decode(data)
+}
+
+// Downlink encodes the payload into a downlink request with the default encoder
+func (p Payload) Downlink(port int, confirmed bool) (nlttypes.DownlinkRequest, error) {
+	return p.DownlinkWith(gonlt.DefaultDownlinkEncoder(), port, confirmed)
+}
+
+// DownlinkWith encodes the payload into a downlink request with the given encoder
+func (p Payload) DownlinkWith(encoder gonlt.DownlinkEncoder, port int, confirmed bool) (nlttypes.DownlinkRequest, error) {
+	data, err := p.Encode()
+	if err != nil {
+		return nlttypes.DownlinkRequest{}, err
+	}
+
+	return encoder.Bytes(port, data, confirmed)
+}
+
+// Add appends a scalar reading, range errors are reported by Encode
+func (p *Payload) Add(channel uint8, typ Type, value float64) *Payload {
+	*p = append(*p, Reading{Channel: channel, Type: typ, Value: value})
+
+	return p
+}
+
+func (p *Payload) AddDigitalInput(channel uint8, value uint8) *Payload {
+	return p.Add(channel, DigitalInput, float64(value))
+}
+
+func (p *Payload) AddDigitalOutput(channel uint8, value uint8) *Payload {
+	return p.Add(channel, DigitalOutput, float64(value))
+}
+
+func (p *Payload) AddAnalogInput(channel uint8, value float64) *Payload {
+	return p.Add(channel, AnalogInput, value)
+}
+
+func (p *Payload) AddAnalogOutput(channel uint8, value float64) *Payload {
+	return p.Add(channel, AnalogOutput, value)
+}
+
+// AddIlluminance in lux
+func (p *Payload) AddIlluminance(channel uint8, lux float64) *Payload {
+	return p.Add(channel, Illuminance, lux)
+}
+
+func (p *Payload) AddPresence(channel uint8, value uint8) *Payload {
+	return p.Add(channel, Presence, float64(value))
+}
+
+// AddTemperature in °C
+func (p *Payload) AddTemperature(channel uint8, celsius float64) *Payload {
+	return p.Add(channel, Temperature, celsius)
+}
+
+// AddHumidity in %RH
+func (p *Payload) AddHumidity(channel uint8, rh float64) *Payload {
+	return p.Add(channel, Humidity, rh)
+}
+
+// AddBarometer in hPa
+func (p *Payload) AddBarometer(channel uint8, hpa float64) *Payload {
+	return p.Add(channel, Barometer, hpa)
+}
+
+// AddAccelerometer in G
+func (p *Payload) AddAccelerometer(channel uint8, x, y, z float64) *Payload {
+	*p = append(*p, Reading{Channel: channel, Type: Accelerometer, Vector: &Vector{X: x, Y: y, Z: z}})
+
+	return p
+}
+
+// AddGyrometer in °/s
+func (p *Payload) AddGyrometer(channel uint8, x, y, z float64) *Payload {
+	*p = append(*p, Reading{Channel: channel, Type: Gyrometer, Vector: &Vector{X: x, Y: y, Z: z}})
+
+	return p
+}
+
+// AddGPS with latitude and longitude in degrees and altitude in meters
+func (p *Payload) AddGPS(channel uint8, latitude, longitude, altitude float64) *Payload {
+	*p = append(*p, Reading{Channel: channel, Type: GPS, Location: &Location{Latitude: latitude, Longitude: longitude, Altitude: altitude}})
+
+	return p
+}
+
+func (p *Payload) AddColour(channel uint8, r, g, b uint8) *Payload {
+	*p = append(*p, Reading{Channel: channel, Type: Colour, Colour: &RGB{R: r, G: g, B: b}})
+
+	return p
+}
@@ -0,0 +1,288 @@
+package gonlt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// DefaultConnectionType is the type of the connections built by NewConnectionBuilder
+const DefaultConnectionType = "http"
+
+// ErrSelfTest is returned when the URL of a connection fails its self-test
+var ErrSelfTest = errors.New("gonlt: connection self-test failed")
+
+// ConnectionBuilder builds the request of a connection pushing messages to a URL
+type ConnectionBuilder struct {
+	conn   nlttypes.Connectionmodel
+	filter nlttypes.Filtermodel
+
+	selfTest bool
+}
+
+// NewConnectionBuilder starts a http connection to the URL
+func NewConnectionBuilder(rawURL string) *ConnectionBuilder {
+	return &ConnectionBuilder{
+		conn: nlttypes.Connectionmodel{
+			URL:            rawURL,
+			ConnectionType: DefaultConnectionType,
+		},
+	}
+}
+
+// Description of the connection and of its filter
+func (b *ConnectionBuilder) Description(description string) *ConnectionBuilder {
+	b.conn.Description = description
+	b.filter.Description = description
+
+	return b
+}
+
+// AuthHeader sent by NLT with every pushed request
+func (b *ConnectionBuilder) AuthHeader(header string) *ConnectionBuilder {
+	b.conn.AuthHeader = header
+
+	return b
+}
+
+// ConnectionType of the connection, defaults to DefaultConnectionType
+func (b *ConnectionBuilder) ConnectionType(connectionType string) *ConnectionBuilder {
+	b.conn.ConnectionType = connectionType
+
+	return b
+}
+
+// Applications whose messages are pushed, all when empty
+func (b *ConnectionBuilder) Applications(applications ...string) *ConnectionBuilder {
+	b.filter.Applications = append(b.filter.Applications, applications...)
+
+	return b
+}
+
+// Devices whose messages are pushed, all when empty
+func (b *ConnectionBuilder) Devices(devEuis ...string) *ConnectionBuilder {
+	b.filter.Devices = append(b.filter.Devices, devEuis...)
+
+	return b
+}
+
+// Gateways whose messages are pushed, all when empty
+func (b *ConnectionBuilder) Gateways(gateways ...string) *ConnectionBuilder {
+	b.filter.Gateways = append(b.filter.Gateways, gateways...)
+
+	return b
+}
+
+// Tags of the devices whose messages are pushed, all when empty
+func (b *ConnectionBuilder) Tags(tags ...string) *ConnectionBuilder {
+	b.filter.Tags = append(b.filter.Tags, tags...)
+
+	return b
+}
+
+// Types of the messages pushed, all when empty
+func (b *ConnectionBuilder) Types(types ...nlttypes.MessageType) *ConnectionBuilder {
+	for _, t := range types {
+		b.filter.Types = append(b.filter.Types, string(t))
+	}
+
+	return b
+}
+
+// Duplicate pushes the duplicates of uplinks received by several gateways
+func (b *ConnectionBuilder) Duplicate(enabled bool) *ConnectionBuilder {
+	b.filter.Duplicate = enabled
+
+	return b
+}
+
+// Lora includes the LoRa parameters in the pushed messages
+func (b *ConnectionBuilder) Lora(enabled bool) *ConnectionBuilder {
+	b.filter.Lora = enabled
+
+	return b
+}
+
+// Radio includes the radio parameters in the pushed messages
+func (b *ConnectionBuilder) Radio(enabled bool) *ConnectionBuilder {
+	b.filter.Radio = enabled
+
+	return b
+}
+
+// WithTags includes the device tags in the pushed messages
+func (b *ConnectionBuilder) WithTags(enabled bool) *ConnectionBuilder {
+	b.filter.WithTags = enabled
+
+	return b
+}
+
+// Disabled creates the connection with its filter disabled
+func (b *ConnectionBuilder) Disabled(disabled bool) *ConnectionBuilder {
+	b.filter.IsDisabled = disabled
+
+	return b
+}
+
+// SelfTest makes EnsureConnection call TestConnection before creating or
+// updating the connection, so a URL that can't receive messages is never
+// registered
+func (b *ConnectionBuilder) SelfTest(enabled bool) *ConnectionBuilder {
+	b.selfTest = enabled
+
+	return b
+}
+
+// Validate checks the URL, the connection type and the message types
+func (b *ConnectionBuilder) Validate() error {
+	u, err := url.Parse(b.conn.URL)
+	if err != nil {
+		return fmt.Errorf("%w: invalid connection url %q: %v", ErrValidation, b.conn.URL, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: connection url %q must be http or https", ErrValidation, b.conn.URL)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("%w: connection url %q has no host", ErrValidation, b.conn.URL)
+	}
+
+	if strings.TrimSpace(b.conn.ConnectionType) == "" {
+		return fmt.Errorf("%w: connection type is required", ErrValidation)
+	}
+
+	for _, t := range b.filter.Types {
+		if !nlttypes.MessageType(t).Valid() {
+			return fmt.Errorf("%w: unknown message type %q", ErrValidation, t)
+		}
+	}
+
+	return nil
+}
+
+// Build validates and returns the create request
+func (b *ConnectionBuilder) Build() (nlttypes.CreateConnectionRequest, error) {
+	if err := b.Validate(); err != nil {
+		return nlttypes.CreateConnectionRequest{}, err
+	}
+
+	return nlttypes.CreateConnectionRequest{
+		Connectionmodel: b.conn,
+		Filtermodel:     b.filter,
+	}, nil
+}
+
+// matches reports whether an existing connection is the one the builder
+// describes, by description when set, by URL otherwise
+func (b *ConnectionBuilder) matches(data nlttypes.Data) bool {
+	if b.conn.Description != "" {
+		return data.Connectionmodel.Description == b.conn.Description
+	}
+
+	return data.Connectionmodel.URL == b.conn.URL
+}
+
+// EnsureConnection creates the connection built by b, or updates the fields
+// that differ in the existing connection with the same description, or the
+// same URL when b has no description. It reports whether the connection was
+// created. With SelfTest the URL is tested first and nothing is changed
+// when the test fails.
+func (s ConnectionServiceOp) EnsureConnection(ctx context.Context, b *ConnectionBuilder) (*nlttypes.Data, bool, error) {
+	req, err := b.Build()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if b.selfTest {
+		if err := s.TestConnection(ctx, b); err != nil {
+			return nil, false, err
+		}
+	}
+
+	connections, err := s.All(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, existing := range connections {
+		if !b.matches(existing) {
+			continue
+		}
+
+		patch := DiffConnection(existing, req)
+		if patch.Empty() {
+			return &existing, false, nil
+		}
+
+		resp, err := s.Update(ctx, existing.Connectionmodel.ID, patch)
+		if err != nil {
+			return nil, false, err
+		}
+
+		return &nlttypes.Data{
+			Connectionmodel: resp.Connectionmodel,
+			Filtermodel:     resp.Filtermodel,
+		}, false, nil
+	}
+
+	resp, err := s.Create(ctx, req)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &nlttypes.Data{
+		Connectionmodel: resp.Connectionmodel,
+		Filtermodel:     resp.Filtermodel,
+	}, true, nil
+}
+
+// TestConnection posts an empty list of messages to the URL of the connection
+// with its auth header, the way NLT pushes messages, and fails with
+// ErrSelfTest unless the URL answers with a 2xx status. A webhook.Receiver
+// accepts the test without calling any handler.
+func (s ConnectionServiceOp) TestConnection(ctx context.Context, b *ConnectionBuilder) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.conn.URL, bytes.NewReader([]byte("[]")))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSelfTest, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if b.conn.AuthHeader != "" {
+		req.Header.Set("Authorization", b.conn.AuthHeader)
+	}
+
+	httpClient := s.cfg.httpClient
+	if httpClient == nil {
+		httpClient = &http.Client{
+			Timeout:   s.cfg.timeout,
+			Transport: s.cfg.transport,
+		}
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: POST %s: %v", ErrSelfTest, b.conn.URL, err)
+	}
+	defer resp.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: POST %s: %s", ErrSelfTest, b.conn.URL, resp.Status)
+	}
+
+	return nil
+}
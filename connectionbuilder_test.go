@@ -0,0 +1,220 @@
+package gonlt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/douglaszuqueto/gonlt/credentials"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+	"github.com/vingarcia/krest"
+)
+
+// connectionAPI is a stand-in for the connection endpoints recording the
+// mutating requests
+type connectionAPI struct {
+	existing []nlttypes.Data
+	calls    []string
+	bodies   []map[string]interface{}
+}
+
+func (a *connectionAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		_ = json.NewEncoder(w).Encode(nlttypes.ConnectionResponse{Total: len(a.existing), Data: a.existing})
+		return
+	}
+
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	a.calls = append(a.calls, r.Method+" "+r.URL.Path)
+	a.bodies = append(a.bodies, body)
+
+	_, _ = w.Write([]byte(`{"connections":{"id":1},"filters":{"id":1}}`))
+}
+
+func newConnectionAPI(t *testing.T, existing ...nlttypes.Data) (*connectionAPI, ConnectionServiceOp) {
+	t.Helper()
+
+	api := &connectionAPI{existing: existing}
+
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	return api, NewConnectionService(krest.New(defaultTimeout), credentials.NewTokenStore("token"),
+		WithBaseURL(srv.URL), WithMaxRetries(1))
+}
+
+// webhookServer accepts POSTs carrying the auth header
+func webhookServer(t *testing.T, authHeader string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != authHeader {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestConnectionBuilderValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *ConnectionBuilder
+		wantErr bool
+	}{
+		{"valid", NewConnectionBuilder("https://example.com/nlt").Types(nlttypes.MessageUplink), false},
+		{"relative url", NewConnectionBuilder("/nlt"), true},
+		{"not http", NewConnectionBuilder("ftp://example.com"), true},
+		{"no connection type", NewConnectionBuilder("https://example.com").ConnectionType(" "), true},
+		{"unknown message type", NewConnectionBuilder("https://example.com").Types("bogus"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.builder.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil && !errors.Is(err, ErrValidation) {
+				t.Errorf("error %v is not ErrValidation", err)
+			}
+		})
+	}
+}
+
+func TestTestConnection(t *testing.T) {
+	hook := webhookServer(t, "secret")
+	_, s := newConnectionAPI(t)
+
+	if err := s.TestConnection(context.Background(), NewConnectionBuilder(hook.URL).AuthHeader("secret")); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	err := s.TestConnection(context.Background(), NewConnectionBuilder(hook.URL).AuthHeader("wrong"))
+	if !errors.Is(err, ErrSelfTest) {
+		t.Errorf("got %v, want ErrSelfTest", err)
+	}
+}
+
+func TestEnsureConnectionSelfTestFailureChangesNothing(t *testing.T) {
+	hook := webhookServer(t, "secret")
+	api, s := newConnectionAPI(t)
+
+	b := NewConnectionBuilder(hook.URL).AuthHeader("wrong").SelfTest(true)
+
+	if _, _, err := s.EnsureConnection(context.Background(), b); !errors.Is(err, ErrSelfTest) {
+		t.Fatalf("got %v, want ErrSelfTest", err)
+	}
+
+	if len(api.calls) != 0 {
+		t.Errorf("API called despite the failed self-test: %v", api.calls)
+	}
+}
+
+func TestEnsureConnection(t *testing.T) {
+	existing := nlttypes.Data{
+		Connectionmodel: nlttypes.Connectionmodel{
+			ID:             7,
+			URL:            "https://example.com/nlt",
+			ConnectionType: DefaultConnectionType,
+			Description:    "farm",
+		},
+		Filtermodel: nlttypes.Filtermodel{
+			ID:          9,
+			Description: "farm",
+			Types:       []string{"uplink"},
+		},
+	}
+
+	builder := func() *ConnectionBuilder {
+		return NewConnectionBuilder("https://example.com/nlt").Description("farm").Types(nlttypes.MessageUplink)
+	}
+
+	t.Run("create", func(t *testing.T) {
+		api, s := newConnectionAPI(t)
+
+		_, created, err := s.EnsureConnection(context.Background(), builder())
+		if err != nil || !created {
+			t.Fatalf("created = %v, err = %v", created, err)
+		}
+
+		if len(api.calls) != 1 || api.calls[0] != "POST /connections" {
+			t.Errorf("calls = %v", api.calls)
+		}
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		api, s := newConnectionAPI(t, existing)
+
+		if _, created, err := s.EnsureConnection(context.Background(), builder()); err != nil || created {
+			t.Fatalf("created = %v, err = %v", created, err)
+		}
+
+		if len(api.calls) != 0 {
+			t.Errorf("calls = %v", api.calls)
+		}
+	})
+
+	t.Run("update changed fields only", func(t *testing.T) {
+		api, s := newConnectionAPI(t, existing)
+
+		if _, _, err := s.EnsureConnection(context.Background(), builder().Radio(true)); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(api.calls) != 1 || api.calls[0] != "PATCH /connections/7" {
+			t.Fatalf("calls = %v", api.calls)
+		}
+
+		want := map[string]interface{}{"filters": map[string]interface{}{"radio": true}}
+		if got, _ := json.Marshal(api.bodies[0]); string(got) != mustJSON(t, want) {
+			t.Errorf("body = %s, want %s", got, mustJSON(t, want))
+		}
+	})
+}
+
+func TestEnsureConnectionUpdatesFilterDescription(t *testing.T) {
+	api, s := newConnectionAPI(t, nlttypes.Data{
+		Connectionmodel: nlttypes.Connectionmodel{
+			ID:             7,
+			URL:            "https://example.com/nlt",
+			ConnectionType: DefaultConnectionType,
+			Description:    "farm",
+		},
+		Filtermodel: nlttypes.Filtermodel{ID: 9, Description: "old"},
+	})
+
+	if _, _, err := s.EnsureConnection(context.Background(), NewConnectionBuilder("https://example.com/nlt").Description("farm")); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(api.calls) != 1 {
+		t.Fatalf("calls = %v, want one update", api.calls)
+	}
+
+	want := map[string]interface{}{"filters": map[string]interface{}{"description": "farm"}}
+	if got, _ := json.Marshal(api.bodies[0]); string(got) != mustJSON(t, want) {
+		t.Errorf("body = %s, want %s", got, mustJSON(t, want))
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
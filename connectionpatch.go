@@ -0,0 +1,142 @@
+package gonlt
+
+import (
+	"github.com/douglaszuqueto/gonlt/internal/strs"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// ConnectionPatch is a partial update of a connection. Nil fields are left
+// unchanged, set a list to an empty non nil slice to clear it.
+type ConnectionPatch struct {
+	URL            *string
+	AuthHeader     *string
+	ConnectionType *string
+	Description    *string
+
+	// FilterDescription is the description of the filter of the connection
+	FilterDescription *string
+
+	Applications []string
+	Devices      []string
+	Gateways     []string
+	Tags         []string
+	Types        []string
+
+	Duplicate  *bool
+	Lora       *bool
+	Radio      *bool
+	WithTags   *bool
+	IsDisabled *bool
+}
+
+// DiffConnection returns the patch turning the current connection into the desired one
+func DiffConnection(current nlttypes.Data, desired nlttypes.CreateConnectionRequest) ConnectionPatch {
+	var patch ConnectionPatch
+
+	have, want := current.Connectionmodel, desired.Connectionmodel
+	patch.URL = diffString(have.URL, want.URL)
+	patch.AuthHeader = diffString(have.AuthHeader, want.AuthHeader)
+	patch.ConnectionType = diffString(have.ConnectionType, want.ConnectionType)
+	patch.Description = diffString(have.Description, want.Description)
+
+	hf, wf := current.Filtermodel, desired.Filtermodel
+	patch.FilterDescription = diffString(hf.Description, wf.Description)
+	patch.Applications = diffStrings(hf.Applications, wf.Applications)
+	patch.Devices = diffStrings(hf.Devices, wf.Devices)
+	patch.Gateways = diffStrings(hf.Gateways, wf.Gateways)
+	patch.Tags = diffStrings(hf.Tags, wf.Tags)
+	patch.Types = diffStrings(hf.Types, wf.Types)
+
+	patch.Duplicate = diffBool(hf.Duplicate, wf.Duplicate)
+	patch.Lora = diffBool(hf.Lora, wf.Lora)
+	patch.Radio = diffBool(hf.Radio, wf.Radio)
+	patch.WithTags = diffBool(hf.WithTags, wf.WithTags)
+	patch.IsDisabled = diffBool(hf.IsDisabled, wf.IsDisabled)
+
+	return patch
+}
+
+// Empty reports whether the patch changes nothing
+func (p ConnectionPatch) Empty() bool {
+	return len(p.body()) == 0
+}
+
+// body returns the request body with the connection and filter fields set
+func (p ConnectionPatch) body() map[string]map[string]interface{} {
+	conn := map[string]interface{}{}
+	setString(conn, "url", p.URL)
+	setString(conn, "auth_header", p.AuthHeader)
+	setString(conn, "connection_type", p.ConnectionType)
+	setString(conn, "description", p.Description)
+
+	filter := map[string]interface{}{}
+	setString(filter, "description", p.FilterDescription)
+	setStrings(filter, "applications", p.Applications)
+	setStrings(filter, "devices", p.Devices)
+	setStrings(filter, "gateways", p.Gateways)
+	setStrings(filter, "tags", p.Tags)
+	setStrings(filter, "types", p.Types)
+	setBool(filter, "duplicate", p.Duplicate)
+	setBool(filter, "lora", p.Lora)
+	setBool(filter, "radio", p.Radio)
+	setBool(filter, "with_tags", p.WithTags)
+	setBool(filter, "is_disabled", p.IsDisabled)
+
+	body := map[string]map[string]interface{}{}
+
+	if len(conn) > 0 {
+		body["connections"] = conn
+	}
+
+	if len(filter) > 0 {
+		body["filters"] = filter
+	}
+
+	return body
+}
+
+func setString(m map[string]interface{}, key string, v *string) {
+	if v != nil {
+		m[key] = *v
+	}
+}
+
+func setStrings(m map[string]interface{}, key string, v []string) {
+	if v != nil {
+		m[key] = v
+	}
+}
+
+func setBool(m map[string]interface{}, key string, v *bool) {
+	if v != nil {
+		m[key] = *v
+	}
+}
+
+func diffString(have, want string) *string {
+	if have == want {
+		return nil
+	}
+
+	return &want
+}
+
+func diffStrings(have, want []string) []string {
+	if strs.Same(have, want) {
+		return nil
+	}
+
+	if want == nil {
+		return []string{}
+	}
+
+	return want
+}
+
+func diffBool(have, want bool) *bool {
+	if have == want {
+		return nil
+	}
+
+	return &want
+}
@@ -3,7 +3,9 @@ package gonlt
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/douglaszuqueto/gonlt/credentials"
 	"github.com/douglaszuqueto/gonlt/nlttypes"
@@ -11,48 +13,76 @@ import (
 )
 
 type ConnectionService interface {
-	// List all connections
-	List(ctx context.Context) (*nlttypes.ConnectionResponse, error)
+	// List a page of connections
+	List(ctx context.Context, opts ListOptions) (*nlttypes.ConnectionResponse, error)
+
+	// Iterate over every connection
+	Iterate(ctx context.Context, opts ListOptions, fn func(nlttypes.Data) error) error
+
+	// All returns every connection
+	All(ctx context.Context) ([]nlttypes.Data, error)
 
 	// Create a new connection
 	Create(ctx context.Context, req nlttypes.CreateConnectionRequest) (*nlttypes.CreateConnectionResponse, error)
 
-	// Update a connection
-	Update(ctx context.Context, req nlttypes.UpdateConnectionRequest) (*nlttypes.UpdateConnectionResponse, error)
+	// Find a connection by id
+	Find(ctx context.Context, id int) (*nlttypes.Data, error)
+
+	// Update the fields of a connection set in the patch
+	Update(ctx context.Context, id int, patch ConnectionPatch) (*nlttypes.UpdateConnectionResponse, error)
+
+	// Enable the filter of a connection
+	Enable(ctx context.Context, id int) error
+
+	// Disable the filter of a connection
+	Disable(ctx context.Context, id int) error
 
-	// Delete a connection
+	// Delete a connection, any 2xx status is a success
 	Delete(ctx context.Context, id int) error
+
+	// EnsureConnection creates or updates the connection built by b
+	EnsureConnection(ctx context.Context, b *ConnectionBuilder) (*nlttypes.Data, bool, error)
+
+	// TestConnection checks that the URL of the connection accepts pushed messages
+	TestConnection(ctx context.Context, b *ConnectionBuilder) error
 }
 
 type ConnectionServiceOp struct {
-	rest  krest.Client
-	creds *credentials.Credentials
+	rest   krest.Client
+	tokens credentials.TokenSource
+	cfg    *config
 }
 
 var _ ConnectionService = &ConnectionServiceOp{}
 
-func NewConnectionService(rest krest.Client, creds *credentials.Credentials) ConnectionServiceOp {
+func NewConnectionService(rest krest.Client, tokens credentials.TokenSource, opts ...Option) ConnectionServiceOp {
+	return newConnectionService(rest, tokens, newConfig(opts...))
+}
+
+// newConnectionService creates the service sharing the config of a Client
+func newConnectionService(rest krest.Client, tokens credentials.TokenSource, cfg *config) ConnectionServiceOp {
 	return ConnectionServiceOp{
-		rest:  rest,
-		creds: creds,
+		rest:   rest,
+		tokens: tokens,
+		cfg:    cfg,
 	}
 }
 
-func (s ConnectionServiceOp) List(ctx context.Context) (*nlttypes.ConnectionResponse, error) {
-	endpoint := buildEndpoint("connections")
+// List a page of connections
+func (s ConnectionServiceOp) List(ctx context.Context, opts ListOptions) (*nlttypes.ConnectionResponse, error) {
+	endpoint := s.cfg.endpoint("connections?" + opts.Build())
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := s.rest.Get(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return nil, handleUnauthorizedError(resp.Body)
-		}
-
-		return nil, err
+		return nil, handleError(http.MethodGet, endpoint, resp, err)
 	}
 
 	var connections nlttypes.ConnectionResponse
@@ -65,23 +95,60 @@ func (s ConnectionServiceOp) List(ctx context.Context) (*nlttypes.ConnectionResp
 	return &connections, nil
 }
 
+// Iterate calls fn for every connection starting at opts.Offset, fetching
+// pages of opts.Limit connections as needed. It stops at the first error.
+func (s ConnectionServiceOp) Iterate(ctx context.Context, opts ListOptions, fn func(nlttypes.Data) error) error {
+	for {
+		page, err := s.List(ctx, opts)
+		if err != nil {
+			return err
+		}
+
+		for _, connection := range page.Data {
+			if err := fn(connection); err != nil {
+				return err
+			}
+		}
+
+		opts = opts.next(len(page.Data))
+
+		if len(page.Data) == 0 || opts.Offset >= page.Total {
+			return nil
+		}
+	}
+}
+
+// All returns every connection
+func (s ConnectionServiceOp) All(ctx context.Context) ([]nlttypes.Data, error) {
+	var connections []nlttypes.Data
+
+	err := s.Iterate(ctx, ListOptions{}, func(connection nlttypes.Data) error {
+		connections = append(connections, connection)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return connections, nil
+}
+
 // Create a new connection
 func (s ConnectionServiceOp) Create(ctx context.Context, req nlttypes.CreateConnectionRequest) (*nlttypes.CreateConnectionResponse, error) {
-	endpoint := buildEndpoint("connections")
+	endpoint := s.cfg.endpoint("connections")
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := s.rest.Post(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 		Body:       req,
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return nil, handleUnauthorizedError(resp.Body)
-		}
-
-		return nil, err
+		return nil, handleError(http.MethodPost, endpoint, resp, err)
 	}
 
 	var connection nlttypes.CreateConnectionResponse
@@ -94,25 +161,51 @@ func (s ConnectionServiceOp) Create(ctx context.Context, req nlttypes.CreateConn
 	return &connection, nil
 }
 
-// Update a connection
-func (s ConnectionServiceOp) Update(ctx context.Context, req nlttypes.UpdateConnectionRequest) (*nlttypes.UpdateConnectionResponse, error) {
-	endpoint := buildEndpoint(fmt.Sprintf("connections/%d", req.Connectionmodel.ID))
+// Find returns the connection with the id, looking it up in the listing
+func (s ConnectionServiceOp) Find(ctx context.Context, id int) (*nlttypes.Data, error) {
+	var found *nlttypes.Data
 
-	resp, err := s.rest.Patch(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
-		Body:       req,
-	})
-	if err != nil {
-		if resp.StatusCode == 401 {
-			return nil, handleUnauthorizedError(resp.Body)
+	err := s.Iterate(ctx, ListOptions{}, func(connection nlttypes.Data) error {
+		if connection.Connectionmodel.ID == id {
+			found = &connection
+			return errStopIteration
 		}
 
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStopIteration) {
 		return nil, err
 	}
 
+	if found == nil {
+		return nil, fmt.Errorf("%w: connection %d", ErrNotFound, id)
+	}
+
+	return found, nil
+}
+
+// Update sends the fields set in the patch, the others are left unchanged
+func (s ConnectionServiceOp) Update(ctx context.Context, id int, patch ConnectionPatch) (*nlttypes.UpdateConnectionResponse, error) {
+	endpoint := s.cfg.endpoint(fmt.Sprintf("connections/%d", id))
+
+	if patch.Empty() {
+		return nil, fmt.Errorf("%w: empty connection patch", ErrValidation)
+	}
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.rest.Patch(ctx, endpoint, krest.RequestData{
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
+		Body:       patch.body(),
+	})
+	if err != nil {
+		return nil, handleError(http.MethodPatch, endpoint, resp, err)
+	}
+
 	var connection nlttypes.UpdateConnectionResponse
 
 	err = json.Unmarshal(resp.Body, &connection)
@@ -123,33 +216,39 @@ func (s ConnectionServiceOp) Update(ctx context.Context, req nlttypes.UpdateConn
 	return &connection, nil
 }
 
-// Delete a connection
-func (s ConnectionServiceOp) Delete(ctx context.Context, id int) error {
-	endpoint := buildEndpoint(fmt.Sprintf("connections/%d", id))
+// Enable the filter of a connection so NLT pushes messages again
+func (s ConnectionServiceOp) Enable(ctx context.Context, id int) error {
+	disabled := false
 
-	resp, err := s.rest.Delete(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
-	})
-	if err != nil {
-		if resp.StatusCode == 401 {
-			return handleUnauthorizedError(resp.Body)
-		}
+	_, err := s.Update(ctx, id, ConnectionPatch{IsDisabled: &disabled})
 
-		return err
-	}
+	return err
+}
+
+// Disable the filter of a connection so NLT stops pushing messages
+func (s ConnectionServiceOp) Disable(ctx context.Context, id int) error {
+	disabled := true
 
-	var response nlttypes.DeleteConnectionResponse
+	_, err := s.Update(ctx, id, ConnectionPatch{IsDisabled: &disabled})
 
-	err = json.Unmarshal(resp.Body, &response)
+	return err
+}
+
+// Delete a connection, any 2xx status is a success whatever the body says
+func (s ConnectionServiceOp) Delete(ctx context.Context, id int) error {
+	endpoint := s.cfg.endpoint(fmt.Sprintf("connections/%d", id))
+
+	headers, err := authHeaders(ctx, s.tokens)
 	if err != nil {
 		return err
 	}
 
-	if response.Message != "Connection deleted." {
-		return fmt.Errorf("unexpected response: %s", response.Message)
+	resp, err := s.rest.Delete(ctx, endpoint, krest.RequestData{
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
+	})
+	if err != nil {
+		return handleError(http.MethodDelete, endpoint, resp, err)
 	}
 
 	return nil
@@ -9,8 +9,12 @@ type Credentials struct {
 	AutoLogin bool   `json:"auto_login"`
 }
 
-// Validate credentials
+// Validate credentials, a token is enough unless AutoLogin is set
 func (c *Credentials) Validate() error {
+	if c.Token != "" && !c.AutoLogin {
+		return nil
+	}
+
 	if c.Email == "" {
 		return errors.New("Email is required")
 	}
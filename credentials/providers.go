@@ -0,0 +1,158 @@
+package credentials
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Environment variables read by FromEnv
+const (
+	EnvEmail     = "GONLT_EMAIL"
+	EnvPasswd    = "GONLT_PASSWD"
+	EnvToken     = "GONLT_TOKEN"
+	EnvAutoLogin = "GONLT_AUTO_LOGIN"
+)
+
+// FromEnv loads the credentials from the GONLT_* environment variables.
+// AutoLogin defaults to true when both email and password are set.
+func FromEnv() (Credentials, error) {
+	creds := Credentials{
+		Email:  os.Getenv(EnvEmail),
+		Passwd: os.Getenv(EnvPasswd),
+		Token:  os.Getenv(EnvToken),
+	}
+
+	creds.AutoLogin = creds.Email != "" && creds.Passwd != ""
+
+	if v, ok := os.LookupEnv(EnvAutoLogin); ok && v != "" {
+		autoLogin, err := strconv.ParseBool(v)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("invalid %s: %w", EnvAutoLogin, err)
+		}
+
+		creds.AutoLogin = autoLogin
+	}
+
+	if err := creds.Validate(); err != nil {
+		return Credentials{}, err
+	}
+
+	return creds, nil
+}
+
+// FromFile loads the credentials from a JSON or YAML file, the format is
+// chosen by the file extension. The keys are the same of the JSON tags.
+func FromFile(path string) (Credentials, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	var creds Credentials
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		err = json.Unmarshal(data, &creds)
+	case ".yaml", ".yml":
+		creds, err = parseYAML(data)
+	default:
+		return Credentials{}, fmt.Errorf("unsupported credentials file extension: %q", ext)
+	}
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := creds.Validate(); err != nil {
+		return Credentials{}, err
+	}
+
+	return creds, nil
+}
+
+// StaticToken builds credentials holding only an already issued token,
+// the client never logs in with them
+func StaticToken(token string) Credentials {
+	return Credentials{
+		Token: token,
+	}
+}
+
+// parseYAML reads a flat "key: value" YAML document
+func parseYAML(data []byte) (Credentials, error) {
+	var creds Credentials
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") || text == "---" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, ":")
+		if !ok {
+			return Credentials{}, fmt.Errorf("line %d: expected key: value", line)
+		}
+
+		key = strings.TrimSpace(key)
+
+		value, err := unquote(strings.TrimSpace(value))
+		if err != nil {
+			return Credentials{}, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch key {
+		case "email":
+			creds.Email = value
+		case "passwd":
+			creds.Passwd = value
+		case "token":
+			creds.Token = value
+		case "auto_login":
+			autoLogin, err := strconv.ParseBool(value)
+			if err != nil {
+				return Credentials{}, fmt.Errorf("line %d: invalid auto_login: %w", line, err)
+			}
+
+			creds.AutoLogin = autoLogin
+		default:
+			return Credentials{}, fmt.Errorf("line %d: unknown key %q", line, key)
+		}
+	}
+
+	return creds, scanner.Err()
+}
+
+// unquote strips the quotes of a value and a trailing " #" comment, a quoted
+// value ends at its closing quote so it may contain " #"
+func unquote(value string) (string, error) {
+	if value != "" && (value[0] == '"' || value[0] == '\'') {
+		end := strings.IndexByte(value[1:], value[0])
+		if end < 0 {
+			return "", errors.New("unterminated quoted value")
+		}
+
+		rest := strings.TrimSpace(value[end+2:])
+		if rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+
+		return value[1 : end+1], nil
+	}
+
+	if strings.HasPrefix(value, "#") {
+		return "", nil
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		return strings.TrimSpace(value[:i]), nil
+	}
+
+	return value, nil
+}
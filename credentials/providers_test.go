@@ -0,0 +1,181 @@
+package credentials
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    Credentials
+		wantErr bool
+	}{
+		{
+			name:  "plain values",
+			input: "email: user@example.com\npasswd: s3cret\nauto_login: true\n",
+			want:  Credentials{Email: "user@example.com", Passwd: "s3cret", AutoLogin: true},
+		},
+		{
+			name:  "document marker and comments",
+			input: "---\n# credentials\nemail: user@example.com # owner\ntoken: abc\n",
+			want:  Credentials{Email: "user@example.com", Token: "abc"},
+		},
+		{
+			name:  "double quoted",
+			input: `passwd: "s3cr#t"`,
+			want:  Credentials{Passwd: "s3cr#t"},
+		},
+		{
+			name:  "quoted value followed by a comment",
+			input: `passwd: "s3cr#t" # prod`,
+			want:  Credentials{Passwd: "s3cr#t"},
+		},
+		{
+			name:  "single quoted with comment marker inside",
+			input: `passwd: 'a #b' # prod`,
+			want:  Credentials{Passwd: "a #b"},
+		},
+		{
+			name:  "hash without space is part of the value",
+			input: "passwd: s3cr#t",
+			want:  Credentials{Passwd: "s3cr#t"},
+		},
+		{
+			name:  "empty value with comment",
+			input: "token: # none",
+			want:  Credentials{},
+		},
+		{
+			name:    "unterminated quote",
+			input:   `passwd: "s3cret`,
+			wantErr: true,
+		},
+		{
+			name:    "text after quoted value",
+			input:   `passwd: "s3cret" extra`,
+			wantErr: true,
+		},
+		{
+			name:    "missing colon",
+			input:   "email user@example.com",
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			input:   "user: someone",
+			wantErr: true,
+		},
+		{
+			name:    "invalid auto_login",
+			input:   "auto_login: maybe",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseYAML([]byte(tt.input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    Credentials
+		wantErr bool
+	}{
+		{
+			name: "email and password enable auto login",
+			env:  map[string]string{EnvEmail: "user@example.com", EnvPasswd: "s3cret"},
+			want: Credentials{Email: "user@example.com", Passwd: "s3cret", AutoLogin: true},
+		},
+		{
+			name: "auto login disabled explicitly",
+			env:  map[string]string{EnvEmail: "user@example.com", EnvPasswd: "s3cret", EnvAutoLogin: "false"},
+			want: Credentials{Email: "user@example.com", Passwd: "s3cret"},
+		},
+		{
+			name: "token only",
+			env:  map[string]string{EnvToken: "abc"},
+			want: Credentials{Token: "abc"},
+		},
+		{
+			name:    "auto login without password",
+			env:     map[string]string{EnvEmail: "user@example.com", EnvAutoLogin: "true"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid auto login",
+			env:     map[string]string{EnvToken: "abc", EnvAutoLogin: "yes please"},
+			wantErr: true,
+		},
+		{
+			name:    "nothing set",
+			env:     map[string]string{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{EnvEmail, EnvPasswd, EnvToken, EnvAutoLogin} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			got, err := FromEnv()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", got)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromFileYAMLQuotedPassword(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.yaml")
+
+	data := "email: user@example.com\npasswd: \"s3cr#t\" # prod\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := FromFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if creds.Passwd != "s3cr#t" {
+		t.Errorf("got password %q, want %q", creds.Passwd, "s3cr#t")
+	}
+}
@@ -0,0 +1,52 @@
+package credentials
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNoToken is returned by a TokenStore that holds no token yet
+var ErrNoToken = errors.New("no token available, login first")
+
+// TokenSource provides the token used to authenticate the requests.
+// Implementations must be safe for concurrent use.
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenStore is a TokenSource whose token can be replaced at any time,
+// it is safe for concurrent use
+type TokenStore struct {
+	mu    sync.RWMutex
+	token string
+}
+
+var _ TokenSource = &TokenStore{}
+
+// NewTokenStore creates a TokenStore holding the given token, which may be empty
+func NewTokenStore(token string) *TokenStore {
+	return &TokenStore{
+		token: token,
+	}
+}
+
+// Token returns the current token
+func (s *TokenStore) Token(ctx context.Context) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.token == "" {
+		return "", ErrNoToken
+	}
+
+	return s.token, nil
+}
+
+// SetToken replaces the current token
+func (s *TokenStore) SetToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.token = token
+}
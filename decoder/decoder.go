@@ -0,0 +1,139 @@
+// Package decoder turns the raw payload of uplink messages into typed values
+package decoder
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/douglaszuqueto/gonlt"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// ErrNoDecoder is returned when no decoder is registered for a message
+var ErrNoDecoder = errors.New("decoder: no decoder registered")
+
+// Decoder decodes a raw payload received on a port
+type Decoder interface {
+	Decode(payload []byte, port int) (interface{}, error)
+}
+
+// DecoderFunc adapts a function to the Decoder interface
+type DecoderFunc func(payload []byte, port int) (interface{}, error)
+
+func (f DecoderFunc) Decode(payload []byte, port int) (interface{}, error) {
+	return f(payload, port)
+}
+
+// Raw is a decoder returning the payload bytes as they are
+var Raw Decoder = DecoderFunc(func(payload []byte, port int) (interface{}, error) {
+	return payload, nil
+})
+
+// Registry selects the decoder of a message by the type of the device that
+// sent it, then by its port, then falls back to the default decoder. The zero
+// value is an empty registry without a default decoder.
+type Registry struct {
+	mu sync.RWMutex
+
+	// Encoding of the message payloads, defaults to gonlt.PayloadBase64
+	Encoding gonlt.PayloadEncoding
+
+	// Default decoder used when no other matches, nil means ErrNoDecoder
+	Default Decoder
+
+	byDeviceType map[string]Decoder
+	byPort       map[int]Decoder
+
+	// deviceTypes maps a device EUI to its type
+	deviceTypes map[string]string
+}
+
+var _ nlttypes.PayloadDecoder = &Registry{}
+
+// NewRegistry creates an empty registry falling back to the Raw decoder
+func NewRegistry() *Registry {
+	return &Registry{
+		Encoding:     gonlt.PayloadBase64,
+		Default:      Raw,
+		byDeviceType: map[string]Decoder{},
+		byPort:       map[int]Decoder{},
+		deviceTypes:  map[string]string{},
+	}
+}
+
+// RegisterDeviceType sets the decoder of the devices of a type
+func (r *Registry) RegisterDeviceType(deviceType string, d Decoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.byDeviceType == nil {
+		r.byDeviceType = map[string]Decoder{}
+	}
+
+	r.byDeviceType[deviceType] = d
+}
+
+// RegisterPort sets the decoder of the messages received on a port
+func (r *Registry) RegisterPort(port int, d Decoder) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.byPort == nil {
+		r.byPort = map[int]Decoder{}
+	}
+
+	r.byPort[port] = d
+}
+
+// AddDevices teaches the registry the type of each device, which messages don't carry
+func (r *Registry) AddDevices(devices ...nlttypes.Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.deviceTypes == nil {
+		r.deviceTypes = map[string]string{}
+	}
+
+	for _, device := range devices {
+		r.deviceTypes[device.DevEui] = device.DeviceType
+	}
+}
+
+// Lookup returns the decoder for a message of the device received on the port
+func (r *Registry) Lookup(devEui string, port int) (Decoder, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if deviceType, ok := r.deviceTypes[devEui]; ok {
+		if d, ok := r.byDeviceType[deviceType]; ok {
+			return d, true
+		}
+	}
+
+	if d, ok := r.byPort[port]; ok {
+		return d, true
+	}
+
+	return r.Default, r.Default != nil
+}
+
+// DecodeMessage decodes the payload of the message
+func (r *Registry) DecodeMessage(msg nlttypes.Message) (interface{}, error) {
+	d, ok := r.Lookup(msg.Meta.Device, msg.Params.Port)
+	if !ok {
+		return nil, fmt.Errorf("%w: device %s, port %d", ErrNoDecoder, msg.Meta.Device, msg.Params.Port)
+	}
+
+	encoding := r.Encoding
+	if encoding == "" {
+		encoding = gonlt.PayloadBase64
+	}
+
+	payload, err := gonlt.DecodePayload(msg.Params.Payload, encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.Decode(payload, msg.Params.Port)
+}
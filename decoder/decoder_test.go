@@ -0,0 +1,44 @@
+package decoder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+func TestZeroRegistry(t *testing.T) {
+	var r Registry
+
+	msg := nlttypes.Message{}
+	msg.Meta.Device = "AA01"
+	msg.Params.Port = 2
+	msg.Params.Payload = "AQI="
+
+	if _, err := r.DecodeMessage(msg); !errors.Is(err, ErrNoDecoder) {
+		t.Fatalf("got %v, want %v", err, ErrNoDecoder)
+	}
+
+	r.RegisterPort(2, Raw)
+	r.RegisterDeviceType("sensor", DecoderFunc(func(payload []byte, port int) (interface{}, error) {
+		return len(payload), nil
+	}))
+
+	got, err := r.DecodeMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := got.([]byte); !ok || len(b) != 2 || b[0] != 1 || b[1] != 2 {
+		t.Errorf("port decoder: got %v, want [1 2]", got)
+	}
+
+	r.AddDevices(nlttypes.Device{DevEui: "AA01", DeviceType: "sensor"})
+
+	got, err = r.DecodeMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 2 {
+		t.Errorf("device type decoder: got %v, want 2", got)
+	}
+}
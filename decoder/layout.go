@@ -0,0 +1,326 @@
+package decoder
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrShortPayload is returned when the payload ends before a field of the layout
+var ErrShortPayload = errors.New("decoder: payload too short")
+
+// Layout decodes a binary payload into a struct described by "layout" tags.
+//
+// The tag starts with the wire type followed by options:
+//
+//	type Reading struct {
+//		Temperature float64 `layout:"int16,scale=0.01"`
+//		Humidity    uint8   `layout:"uint8"`
+//		Battery     float64 `layout:"uint16,le,scale=0.001"`
+//		Serial      string  `layout:"bytes=8,offset=10"`
+//	}
+//
+// Wire types are int8, uint8, int16, uint16, int24, uint24, int32, uint32,
+// int64, uint64, float32, float64, bool and bytes=N. Fields are big endian
+// unless le is set, and follow each other unless offset=N is set. scale
+// multiplies the wire value and requires a float field. Fields without
+// tag are left untouched.
+type Layout struct {
+	typ    reflect.Type
+	fields []layoutField
+}
+
+var _ Decoder = &Layout{}
+
+type layoutField struct {
+	index  int
+	name   string
+	wire   string
+	size   int
+	offset int
+	little bool
+	scale  float64
+}
+
+// NewLayout builds the layout of the struct type of v
+func NewLayout(v interface{}) (*Layout, error) {
+	typ := reflect.TypeOf(v)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("decoder: layout needs a struct, got %v", typ)
+	}
+
+	layout := &Layout{
+		typ: typ,
+	}
+
+	offset := 0
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+
+		tag, ok := sf.Tag.Lookup("layout")
+		if !ok || tag == "-" {
+			continue
+		}
+
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("decoder: field %s: unexported", sf.Name)
+		}
+
+		field, err := parseLayoutTag(tag)
+		if err != nil {
+			return nil, fmt.Errorf("decoder: field %s: %w", sf.Name, err)
+		}
+
+		if field.offset < 0 {
+			field.offset = offset
+		}
+
+		field.index = i
+		field.name = sf.Name
+
+		if err := checkLayoutKind(field, sf.Type); err != nil {
+			return nil, fmt.Errorf("decoder: field %s: %w", sf.Name, err)
+		}
+
+		offset = field.offset + field.size
+		layout.fields = append(layout.fields, field)
+	}
+
+	return layout, nil
+}
+
+// MustLayout is like NewLayout but panics on error, for package level decoders
+func MustLayout(v interface{}) *Layout {
+	layout, err := NewLayout(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return layout
+}
+
+// Size returns the minimum payload size accepted by the layout
+func (l *Layout) Size() int {
+	size := 0
+
+	for _, f := range l.fields {
+		if end := f.offset + f.size; end > size {
+			size = end
+		}
+	}
+
+	return size
+}
+
+// Decode returns a new value of the struct type filled from the payload
+func (l *Layout) Decode(payload []byte, port int) (interface{}, error) {
+	v := reflect.New(l.typ).Elem()
+
+	if err := l.decode(payload, v); err != nil {
+		return nil, err
+	}
+
+	return v.Interface(), nil
+}
+
+// Unmarshal fills the struct pointed by v, which must be of the layout type
+func (l *Layout) Unmarshal(payload []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Type() != l.typ {
+		return fmt.Errorf("decoder: unmarshal needs a *%s", l.typ)
+	}
+
+	return l.decode(payload, rv.Elem())
+}
+
+func (l *Layout) decode(payload []byte, v reflect.Value) error {
+	for _, f := range l.fields {
+		if f.offset+f.size > len(payload) {
+			return fmt.Errorf("%w: field %s needs bytes %d to %d, got %d", ErrShortPayload, f.name, f.offset, f.offset+f.size, len(payload))
+		}
+
+		raw := payload[f.offset : f.offset+f.size]
+		dst := v.Field(f.index)
+
+		switch f.wire {
+		case "bytes":
+			data := append([]byte(nil), raw...)
+
+			if dst.Kind() == reflect.String {
+				dst.SetString(strings.TrimRight(string(data), "\x00"))
+			} else {
+				dst.SetBytes(data)
+			}
+		case "bool":
+			dst.SetBool(raw[0] != 0)
+		case "float32", "float64":
+			dst.SetFloat(readFloat(raw, f) * f.scale)
+		default:
+			if strings.HasPrefix(f.wire, "int") {
+				n := readInt(raw, f)
+
+				if isFloat(dst.Kind()) {
+					dst.SetFloat(float64(n) * f.scale)
+				} else {
+					dst.SetInt(n)
+				}
+			} else {
+				n := readUint(raw, f)
+
+				if isFloat(dst.Kind()) {
+					dst.SetFloat(float64(n) * f.scale)
+				} else {
+					dst.SetUint(n)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseLayoutTag(tag string) (layoutField, error) {
+	parts := strings.Split(tag, ",")
+
+	field := layoutField{
+		offset: -1,
+		scale:  1,
+	}
+
+	wire, size, _ := strings.Cut(strings.TrimSpace(parts[0]), "=")
+	field.wire = wire
+
+	switch wire {
+	case "int8", "uint8", "bool":
+		field.size = 1
+	case "int16", "uint16":
+		field.size = 2
+	case "int24", "uint24":
+		field.size = 3
+	case "int32", "uint32", "float32":
+		field.size = 4
+	case "int64", "uint64", "float64":
+		field.size = 8
+	case "bytes":
+		n, err := strconv.Atoi(size)
+		if err != nil || n <= 0 {
+			return field, fmt.Errorf("invalid bytes size %q", size)
+		}
+
+		field.size = n
+	default:
+		return field, fmt.Errorf("unknown wire type %q", wire)
+	}
+
+	for _, opt := range parts[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+
+		switch key {
+		case "le":
+			field.little = true
+		case "be":
+			field.little = false
+		case "offset":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				return field, fmt.Errorf("invalid offset %q", value)
+			}
+
+			field.offset = n
+		case "scale":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return field, fmt.Errorf("invalid scale %q", value)
+			}
+
+			field.scale = f
+		default:
+			return field, fmt.Errorf("unknown option %q", key)
+		}
+	}
+
+	return field, nil
+}
+
+func checkLayoutKind(f layoutField, typ reflect.Type) error {
+	kind := typ.Kind()
+
+	switch {
+	case f.wire == "bytes":
+		if kind == reflect.String || (kind == reflect.Slice && typ.Elem().Kind() == reflect.Uint8) {
+			return nil
+		}
+
+		return fmt.Errorf("bytes needs a string or []byte, got %s", typ)
+	case f.wire == "bool":
+		if kind == reflect.Bool {
+			return nil
+		}
+
+		return fmt.Errorf("bool needs a bool, got %s", typ)
+	case isFloat(kind):
+		return nil
+	case f.scale != 1:
+		return fmt.Errorf("scale needs a float field, got %s", typ)
+	case strings.HasPrefix(f.wire, "float"):
+		return fmt.Errorf("%s needs a float field, got %s", f.wire, typ)
+	case strings.HasPrefix(f.wire, "int") && kind >= reflect.Int && kind <= reflect.Int64,
+		strings.HasPrefix(f.wire, "uint") && kind >= reflect.Uint && kind <= reflect.Uint64:
+		if typ.Bits() < f.size*8 {
+			return fmt.Errorf("%s doesn't fit in %s", f.wire, typ)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%s can't be stored in %s", f.wire, typ)
+	}
+}
+
+func readUint(raw []byte, f layoutField) uint64 {
+	var v uint64
+
+	for i := range raw {
+		b := raw[i]
+		if f.little {
+			b = raw[len(raw)-1-i]
+		}
+
+		v = v<<8 | uint64(b)
+	}
+
+	return v
+}
+
+func readInt(raw []byte, f layoutField) int64 {
+	v := readUint(raw, f)
+	bits := uint(len(raw) * 8)
+
+	// sign extend
+	return int64(v<<(64-bits)) >> (64 - bits)
+}
+
+func readFloat(raw []byte, f layoutField) float64 {
+	var order binary.ByteOrder = binary.BigEndian
+	if f.little {
+		order = binary.LittleEndian
+	}
+
+	if len(raw) == 4 {
+		return float64(math.Float32frombits(order.Uint32(raw)))
+	}
+
+	return math.Float64frombits(order.Uint64(raw))
+}
+
+func isFloat(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
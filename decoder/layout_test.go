@@ -0,0 +1,266 @@
+package decoder
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLayoutDecode(t *testing.T) {
+	type signed struct {
+		Low  int32 `layout:"int24"`
+		High int32 `layout:"int24"`
+		Max  int64 `layout:"int24"`
+	}
+
+	type unsigned struct {
+		Value uint32 `layout:"uint24"`
+	}
+
+	type endian struct {
+		Big    uint16 `layout:"uint16"`
+		Little uint16 `layout:"uint16,le"`
+		Signed int32  `layout:"int24,le"`
+	}
+
+	type offsets struct {
+		Skip  uint8  `layout:"uint8,offset=3"`
+		After uint16 `layout:"uint16"`
+		First uint8  `layout:"uint8,offset=0"`
+	}
+
+	type scaled struct {
+		Temperature float64 `layout:"int16,scale=0.25"`
+		Battery     float32 `layout:"uint16,le,scale=0.5"`
+		Raw         float64 `layout:"uint8"`
+	}
+
+	type serial struct {
+		Serial string `layout:"bytes=4"`
+		Raw    []byte `layout:"bytes=2"`
+	}
+
+	type flags struct {
+		On  bool    `layout:"bool"`
+		Off bool    `layout:"bool"`
+		F   float32 `layout:"float32"`
+	}
+
+	tests := []struct {
+		name    string
+		v       interface{}
+		payload []byte
+		want    interface{}
+		wantErr error
+	}{
+		{
+			name:    "int24 sign extension",
+			v:       signed{},
+			payload: []byte{0xff, 0xff, 0xfe, 0x00, 0x00, 0x02, 0x7f, 0xff, 0xff},
+			want:    signed{Low: -2, High: 2, Max: 8388607},
+		},
+		{
+			name:    "uint24 is not sign extended",
+			v:       unsigned{},
+			payload: []byte{0xff, 0xff, 0xfe},
+			want:    unsigned{Value: 0xfffffe},
+		},
+		{
+			name:    "le",
+			v:       endian{},
+			payload: []byte{0x12, 0x34, 0x34, 0x12, 0xfe, 0xff, 0xff},
+			want:    endian{Big: 0x1234, Little: 0x1234, Signed: -2},
+		},
+		{
+			name:    "offset",
+			v:       offsets{},
+			payload: []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06},
+			want:    offsets{Skip: 0x04, After: 0x0506, First: 0x01},
+		},
+		{
+			name:    "scale",
+			v:       scaled{},
+			payload: []byte{0xff, 0xf6, 0x0a, 0x00, 0x07},
+			want:    scaled{Temperature: -2.5, Battery: 5, Raw: 7},
+		},
+		{
+			name:    "bytes into string and slice",
+			v:       serial{},
+			payload: []byte{'a', 'b', 0x00, 0x00, 0xca, 0xfe},
+			want:    serial{Serial: "ab", Raw: []byte{0xca, 0xfe}},
+		},
+		{
+			name:    "bool and float",
+			v:       flags{},
+			payload: []byte{0x02, 0x00, 0x3f, 0xc0, 0x00, 0x00},
+			want:    flags{On: true, Off: false, F: 1.5},
+		},
+		{
+			name:    "short payload",
+			v:       offsets{},
+			payload: []byte{0x01, 0x02, 0x03, 0x04, 0x05},
+			wantErr: ErrShortPayload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, err := NewLayout(tt.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := layout.Decode(tt.payload, 1)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayoutSizeAndUnmarshal(t *testing.T) {
+	type reading struct {
+		Temperature float64 `layout:"int16,scale=0.5"`
+		Serial      string  `layout:"bytes=3,offset=6"`
+		Humidity    uint8   `layout:"uint8,offset=2"`
+		Ignored     int
+	}
+
+	layout := MustLayout(&reading{})
+
+	if got := layout.Size(); got != 9 {
+		t.Errorf("size: got %d, want 9", got)
+	}
+
+	got := reading{Ignored: 7}
+
+	err := layout.Unmarshal([]byte{0x00, 0x2a, 0x50, 0x00, 0x00, 0x00, 'x', 'y', 'z'}, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := reading{Temperature: 21, Serial: "xyz", Humidity: 80, Ignored: 7}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if err := layout.Unmarshal(nil, got); err == nil {
+		t.Error("unmarshal into a value: got no error")
+	}
+}
+
+func TestNewLayoutErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{
+			name: "not a struct",
+			v:    42,
+			want: "needs a struct",
+		},
+		{
+			name: "unknown wire type",
+			v: struct {
+				A int16 `layout:"int12"`
+			}{},
+			want: `unknown wire type "int12"`,
+		},
+		{
+			name: "invalid bytes size",
+			v: struct {
+				A string `layout:"bytes=0"`
+			}{},
+			want: "invalid bytes size",
+		},
+		{
+			name: "unknown option",
+			v: struct {
+				A int16 `layout:"int16,swap"`
+			}{},
+			want: `unknown option "swap"`,
+		},
+		{
+			name: "invalid offset",
+			v: struct {
+				A int16 `layout:"int16,offset=-1"`
+			}{},
+			want: "invalid offset",
+		},
+		{
+			name: "unexported",
+			v: struct {
+				a int16 `layout:"int16"`
+			}{},
+			want: "unexported",
+		},
+		{
+			name: "bytes into int",
+			v: struct {
+				A int `layout:"bytes=2"`
+			}{},
+			want: "bytes needs a string or []byte",
+		},
+		{
+			name: "bool into int",
+			v: struct {
+				A int `layout:"bool"`
+			}{},
+			want: "bool needs a bool",
+		},
+		{
+			name: "scale on an int field",
+			v: struct {
+				A int32 `layout:"int16,scale=0.1"`
+			}{},
+			want: "scale needs a float field",
+		},
+		{
+			name: "float into int",
+			v: struct {
+				A int32 `layout:"float32"`
+			}{},
+			want: "float32 needs a float field",
+		},
+		{
+			name: "int too small",
+			v: struct {
+				A int16 `layout:"int24"`
+			}{},
+			want: "int24 doesn't fit in int16",
+		},
+		{
+			name: "uint into int",
+			v: struct {
+				A int32 `layout:"uint16"`
+			}{},
+			want: "uint16 can't be stored in int32",
+		},
+		{
+			name: "int into string",
+			v: struct {
+				A string `layout:"int16"`
+			}{},
+			want: "int16 can't be stored in string",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewLayout(tt.v)
+			if err == nil {
+				t.Fatalf("got no error, want %q", tt.want)
+			}
+
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package gonlt
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// deviceTimeLayouts are the layouts accepted for the device timestamps,
+// timestamps without a zone are read as UTC
+var deviceTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04:05",
+}
+
+// DeviceFilter selects devices, zero fields are ignored
+type DeviceFilter struct {
+	// Tags the device must have, all of them
+	Tags []string
+
+	DevClass   string
+	Activation string
+	ContractID int
+
+	// Active selects devices by their activation state
+	Active *bool
+
+	// Blocked selects devices with uplink or downlink blocked
+	Blocked *bool
+
+	// LastActivity range, devices that were never active only match
+	// LastActivityBefore
+	LastActivityAfter  time.Time
+	LastActivityBefore time.Time
+}
+
+// query returns the parameters the API may use to narrow the listing
+func (f DeviceFilter) query() url.Values {
+	query := url.Values{}
+
+	for _, tag := range f.Tags {
+		query.Add("tags", tag)
+	}
+
+	if f.DevClass != "" {
+		query.Set("dev_class", f.DevClass)
+	}
+
+	if f.Activation != "" {
+		query.Set("activation", f.Activation)
+	}
+
+	if f.ContractID != 0 {
+		query.Set("contract_id", strconv.Itoa(f.ContractID))
+	}
+
+	return query
+}
+
+// Match reports whether the device satisfies the filter
+func (f DeviceFilter) Match(device nlttypes.Device) bool {
+	if !hasTags(device.Tags, f.Tags) {
+		return false
+	}
+
+	if f.DevClass != "" && !strings.EqualFold(device.DevClass, f.DevClass) {
+		return false
+	}
+
+	if f.Activation != "" && !strings.EqualFold(device.Activation, f.Activation) {
+		return false
+	}
+
+	if f.ContractID != 0 && device.ContractID != f.ContractID {
+		return false
+	}
+
+	if f.Active != nil && deviceActive(device) != *f.Active {
+		return false
+	}
+
+	if f.Blocked != nil && (device.BlockUplink || device.BlockDownlink) != *f.Blocked {
+		return false
+	}
+
+	if f.LastActivityAfter.IsZero() && f.LastActivityBefore.IsZero() {
+		return true
+	}
+
+	lastActivity, ok := parseDeviceTime(device.LastActivity)
+	if !ok {
+		return f.LastActivityAfter.IsZero()
+	}
+
+	if !f.LastActivityAfter.IsZero() && lastActivity.Before(f.LastActivityAfter) {
+		return false
+	}
+
+	if !f.LastActivityBefore.IsZero() && !lastActivity.Before(f.LastActivityBefore) {
+		return false
+	}
+
+	return true
+}
+
+func hasTags(tags, required []string) bool {
+	for _, r := range required {
+		found := false
+
+		for _, tag := range tags {
+			if tag == r {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// deviceActive reports whether the device was activated after its last deactivation
+func deviceActive(device nlttypes.Device) bool {
+	activatedAt, ok := parseDeviceTime(device.ActivatedAt)
+	if !ok {
+		return false
+	}
+
+	deactivatedAt, ok := parseDeviceTime(device.DeactivatedAt)
+	if !ok {
+		return true
+	}
+
+	return activatedAt.After(deactivatedAt)
+}
+
+func parseDeviceTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	for _, layout := range deviceTimeLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
@@ -3,8 +3,9 @@ package gonlt
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 
 	"github.com/douglaszuqueto/gonlt/credentials"
 	"github.com/douglaszuqueto/gonlt/nlttypes"
@@ -12,7 +13,10 @@ import (
 )
 
 type DeviceService interface {
-	List(ctx context.Context) (*nlttypes.DeviceListResponse, error)
+	List(ctx context.Context, opts ListOptions) (*nlttypes.DeviceListResponse, error)
+	Iterate(ctx context.Context, opts ListOptions, fn func(nlttypes.Device) error) error
+	All(ctx context.Context) (nlttypes.DeviceListResponse, error)
+	Filter(ctx context.Context, filter DeviceFilter) (nlttypes.DeviceListResponse, error)
 	Find(ctx context.Context, deviceID string) (*nlttypes.Device, error)
 	Create(ctx context.Context, device nlttypes.DeviceCreateRequest) (*nlttypes.Device, error)
 	Update(ctx context.Context, device nlttypes.DeviceUpdateRequest) (*nlttypes.Device, error)
@@ -22,34 +26,45 @@ type DeviceService interface {
 }
 
 type DeviceServiceOp struct {
-	rest  krest.Client
-	creds *credentials.Credentials
+	rest   krest.Client
+	tokens credentials.TokenSource
+	cfg    *config
 }
 
 var _ DeviceService = &DeviceServiceOp{}
 
-func NewDeviceService(rest krest.Client, creds *credentials.Credentials) DeviceServiceOp {
+func NewDeviceService(rest krest.Client, tokens credentials.TokenSource, opts ...Option) DeviceServiceOp {
+	return newDeviceService(rest, tokens, newConfig(opts...))
+}
+
+// newDeviceService creates the service sharing the config of a Client
+func newDeviceService(rest krest.Client, tokens credentials.TokenSource, cfg *config) DeviceServiceOp {
 	return DeviceServiceOp{
-		rest:  rest,
-		creds: creds,
+		rest:   rest,
+		tokens: tokens,
+		cfg:    cfg,
 	}
 }
 
-func (s DeviceServiceOp) List(ctx context.Context) (*nlttypes.DeviceListResponse, error) {
-	endpoint := buildEndpoint("devices?offset=0&limit=100")
+// List a page of devices
+func (s DeviceServiceOp) List(ctx context.Context, opts ListOptions) (*nlttypes.DeviceListResponse, error) {
+	return s.list(ctx, opts.Build())
+}
+
+func (s DeviceServiceOp) list(ctx context.Context, query string) (*nlttypes.DeviceListResponse, error) {
+	endpoint := s.cfg.endpoint("devices?" + query)
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := s.rest.Get(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return nil, handleUnauthorizedError(resp.Body)
-		}
-
-		return nil, err
+		return nil, handleError(http.MethodGet, endpoint, resp, err)
 	}
 
 	var devices nlttypes.DeviceListResponse
@@ -62,27 +77,98 @@ func (s DeviceServiceOp) List(ctx context.Context) (*nlttypes.DeviceListResponse
 	return &devices, nil
 }
 
-func (s DeviceServiceOp) Find(ctx context.Context, deviceID string) (*nlttypes.Device, error) {
-	endpoint := buildEndpoint(fmt.Sprintf("devices/%s", deviceID))
+// Iterate calls fn for every device starting at opts.Offset, fetching
+// pages of opts.Limit devices as needed until an empty or short page.
+// It stops at the first error.
+func (s DeviceServiceOp) Iterate(ctx context.Context, opts ListOptions, fn func(nlttypes.Device) error) error {
+	return s.iterate(ctx, opts, url.Values{}, fn)
+}
 
-	resp, err := s.rest.Get(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+// iterate walks the pages of devices adding query to the pagination parameters
+func (s DeviceServiceOp) iterate(ctx context.Context, opts ListOptions, query url.Values, fn func(nlttypes.Device) error) error {
+	pageSize := 0
+
+	for {
+		pageQuery := opts.Build()
+		if len(query) > 0 {
+			pageQuery += "&" + query.Encode()
+		}
+
+		page, err := s.list(ctx, pageQuery)
+		if err != nil {
+			return err
+		}
+
+		for _, device := range *page {
+			if err := fn(device); err != nil {
+				return err
+			}
+		}
+
+		// the server may cap the requested limit, so only a page shorter
+		// than the ones it returned before marks the end
+		if len(*page) == 0 || len(*page) < pageSize {
+			return nil
+		}
+
+		if len(*page) > pageSize {
+			pageSize = len(*page)
+		}
+
+		opts = opts.next(len(*page))
+	}
+}
+
+// All returns every device of the account
+func (s DeviceServiceOp) All(ctx context.Context) (nlttypes.DeviceListResponse, error) {
+	var devices nlttypes.DeviceListResponse
+
+	err := s.Iterate(ctx, ListOptions{}, func(device nlttypes.Device) error {
+		devices = append(devices, device)
+		return nil
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return nil, handleUnauthorizedError(resp.Body)
-		}
+		return nil, err
+	}
 
-		if resp.StatusCode == 404 {
-			return nil, errors.New("device not found")
+	return devices, nil
+}
+
+// Filter returns every device matching the filter. The filter is sent to
+// the API to narrow the listing and then checked on each returned device.
+func (s DeviceServiceOp) Filter(ctx context.Context, filter DeviceFilter) (nlttypes.DeviceListResponse, error) {
+	var devices nlttypes.DeviceListResponse
+
+	err := s.iterate(ctx, ListOptions{}, filter.query(), func(device nlttypes.Device) error {
+		if filter.Match(device) {
+			devices = append(devices, device)
 		}
 
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return devices, nil
+}
+
+func (s DeviceServiceOp) Find(ctx context.Context, deviceID string) (*nlttypes.Device, error) {
+	endpoint := s.cfg.endpoint(fmt.Sprintf("devices/%s", deviceID))
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
 		return nil, err
 	}
 
+	resp, err := s.rest.Get(ctx, endpoint, krest.RequestData{
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
+	})
+	if err != nil {
+		return nil, handleError(http.MethodGet, endpoint, resp, err)
+	}
+
 	var device nlttypes.Device
 
 	err = json.Unmarshal(resp.Body, &device)
@@ -94,25 +180,20 @@ func (s DeviceServiceOp) Find(ctx context.Context, deviceID string) (*nlttypes.D
 }
 
 func (s DeviceServiceOp) Create(ctx context.Context, device nlttypes.DeviceCreateRequest) (*nlttypes.Device, error) {
-	endpoint := buildEndpoint("devices/create-device")
+	endpoint := s.cfg.endpoint("devices/create-device")
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := s.rest.Post(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 		Body:       device,
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return nil, handleUnauthorizedError(resp.Body)
-		}
-
-		if resp.StatusCode == 400 {
-			return nil, handleDeviceCreateError(resp.Body)
-		}
-
-		return nil, err
+		return nil, handleError(http.MethodPost, endpoint, resp, err)
 	}
 
 	var createdDevice nlttypes.Device
@@ -126,25 +207,20 @@ func (s DeviceServiceOp) Create(ctx context.Context, device nlttypes.DeviceCreat
 }
 
 func (s DeviceServiceOp) Update(ctx context.Context, device nlttypes.DeviceUpdateRequest) (*nlttypes.Device, error) {
-	endpoint := buildEndpoint("devices/" + device.DevEui)
+	endpoint := s.cfg.endpoint("devices/" + device.DevEui)
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := s.rest.Patch(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 		Body:       device,
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return nil, handleUnauthorizedError(resp.Body)
-		}
-
-		if resp.StatusCode == 400 {
-			return nil, handleDeviceCreateError(resp.Body)
-		}
-
-		return nil, err
+		return nil, handleError(http.MethodPatch, endpoint, resp, err)
 	}
 
 	var result nlttypes.Device
@@ -158,23 +234,22 @@ func (s DeviceServiceOp) Update(ctx context.Context, device nlttypes.DeviceUpdat
 }
 
 func (s DeviceServiceOp) Activate(ctx context.Context, deviceID string) error {
-	endpoint := buildEndpoint(fmt.Sprintf("devices/%s/activation", deviceID))
+	endpoint := s.cfg.endpoint(fmt.Sprintf("devices/%s/activation", deviceID))
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return err
+	}
 
 	resp, err := s.rest.Post(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 		Body: map[string]interface{}{
 			"is_active": true,
 		},
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return handleUnauthorizedError(resp.Body)
-		}
-
-		return err
+		return handleError(http.MethodPost, endpoint, resp, err)
 	}
 
 	var device nlttypes.Device
@@ -192,23 +267,22 @@ func (s DeviceServiceOp) Activate(ctx context.Context, deviceID string) error {
 }
 
 func (s DeviceServiceOp) Deactivate(ctx context.Context, deviceID string) error {
-	endpoint := buildEndpoint(fmt.Sprintf("devices/%s/activation", deviceID))
+	endpoint := s.cfg.endpoint(fmt.Sprintf("devices/%s/activation", deviceID))
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return err
+	}
 
 	resp, err := s.rest.Post(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 		Body: map[string]interface{}{
 			"is_active": false,
 		},
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return handleUnauthorizedError(resp.Body)
-		}
-
-		return err
+		return handleError(http.MethodPost, endpoint, resp, err)
 	}
 
 	var device nlttypes.Device
@@ -226,24 +300,19 @@ func (s DeviceServiceOp) Deactivate(ctx context.Context, deviceID string) error
 }
 
 func (s DeviceServiceOp) Delete(ctx context.Context, deviceID string) error {
-	endpoint := buildEndpoint(fmt.Sprintf("devices/%s", deviceID))
+	endpoint := s.cfg.endpoint(fmt.Sprintf("devices/%s", deviceID))
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return err
+	}
 
 	resp, err := s.rest.Delete(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return handleUnauthorizedError(resp.Body)
-		}
-
-		if resp.StatusCode == 404 {
-			return errors.New("device not found")
-		}
-
-		return err
+		return handleError(http.MethodDelete, endpoint, resp, err)
 	}
 
 	var device nlttypes.Device
@@ -0,0 +1,59 @@
+package gonlt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/douglaszuqueto/gonlt/credentials"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+	"github.com/vingarcia/krest"
+)
+
+func TestDeviceIterateServerCappedPages(t *testing.T) {
+	const total, serverMax = 250, 100
+
+	requests := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		if limit > serverMax {
+			limit = serverMax
+		}
+
+		devices := nlttypes.DeviceListResponse{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			devices = append(devices, nlttypes.Device{DevEui: fmt.Sprintf("%016x", i)})
+		}
+
+		_ = json.NewEncoder(w).Encode(devices)
+	}))
+	defer srv.Close()
+
+	s := NewDeviceService(krest.New(defaultTimeout), credentials.NewTokenStore("token"), WithBaseURL(srv.URL))
+
+	seen := 0
+
+	err := s.Iterate(context.Background(), ListOptions{Limit: 500}, func(nlttypes.Device) error {
+		seen++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if seen != total {
+		t.Errorf("iterated %d devices, want %d", seen, total)
+	}
+
+	if requests != 3 {
+		t.Errorf("made %d requests, want 3", requests)
+	}
+}
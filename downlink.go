@@ -3,7 +3,8 @@ package gonlt
 import (
 	"context"
 	"encoding/json"
-	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/douglaszuqueto/gonlt/credentials"
 	"github.com/douglaszuqueto/gonlt/nlttypes"
@@ -13,30 +14,61 @@ import (
 type DownlinkService interface {
 	// Send
 	Send(ctx context.Context, deviceEui string, params nlttypes.DownlinkRequest) (*nlttypes.DownlinkResponse, error)
+
+	// SendBytes encodes and sends a raw payload
+	SendBytes(ctx context.Context, deviceEui string, port int, payload []byte, confirmed bool) (*nlttypes.DownlinkResponse, error)
+
+	// SendAndWait sends a confirmed downlink and waits for its ack
+	SendAndWait(ctx context.Context, deviceEui string, params nlttypes.DownlinkRequest, timeout time.Duration) (*DownlinkResult, error)
 }
 
 type DownlinkServiceOp struct {
-	rest  krest.Client
-	creds *credentials.Credentials
+	rest    krest.Client
+	tokens  credentials.TokenSource
+	cfg     *config
+	encoder DownlinkEncoder
+
+	// messages are polled to track confirmed downlinks
+	messages MessageServiceOp
 }
 
 var _ DownlinkService = &DownlinkServiceOp{}
 
-func NewDownlinkService(rest krest.Client, creds *credentials.Credentials) *DownlinkServiceOp {
-	return &DownlinkServiceOp{
-		rest:  rest,
-		creds: creds,
+func NewDownlinkService(rest krest.Client, tokens credentials.TokenSource, opts ...Option) DownlinkServiceOp {
+	return newDownlinkService(rest, tokens, newConfig(opts...))
+}
+
+// newDownlinkService creates the service sharing the config of a Client
+func newDownlinkService(rest krest.Client, tokens credentials.TokenSource, cfg *config) DownlinkServiceOp {
+	return DownlinkServiceOp{
+		rest:    rest,
+		tokens:  tokens,
+		cfg:     cfg,
+		encoder: DefaultDownlinkEncoder(),
+
+		messages: newMessageService(rest, tokens, cfg),
 	}
 }
 
-func (s *DownlinkServiceOp) Send(ctx context.Context, deviceEui string, params nlttypes.DownlinkRequest) (*nlttypes.DownlinkResponse, error) {
-	endpoint := buildEndpoint("messages/" + deviceEui + "/send-downlink-claim")
+// WithEncoder returns a copy of the service using the encoder in SendBytes
+func (s DownlinkServiceOp) WithEncoder(encoder DownlinkEncoder) DownlinkServiceOp {
+	s.encoder = encoder
 
-	resp, err := s.rest.Get(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+	return s
+}
+
+// Send a downlink to the device
+func (s DownlinkServiceOp) Send(ctx context.Context, deviceEui string, params nlttypes.DownlinkRequest) (*nlttypes.DownlinkResponse, error) {
+	endpoint := s.cfg.endpoint("messages/" + deviceEui + "/send-downlink-claim")
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.rest.Post(ctx, endpoint, krest.RequestData{
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 		Body: map[string]interface{}{
 			"payload":   params.Payload,
 			"port":      params.Port,
@@ -44,11 +76,7 @@ func (s *DownlinkServiceOp) Send(ctx context.Context, deviceEui string, params n
 		},
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return nil, handleUnauthorizedError(resp.Body)
-		}
-
-		return nil, err
+		return nil, handleError(http.MethodPost, endpoint, resp, err)
 	}
 
 	var body nlttypes.DownlinkResponse
@@ -60,3 +88,13 @@ func (s *DownlinkServiceOp) Send(ctx context.Context, deviceEui string, params n
 
 	return &body, nil
 }
+
+// SendBytes validates the port and payload size, encodes the payload and sends it
+func (s DownlinkServiceOp) SendBytes(ctx context.Context, deviceEui string, port int, payload []byte, confirmed bool) (*nlttypes.DownlinkResponse, error) {
+	params, err := s.encoder.Bytes(port, payload, confirmed)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Send(ctx, deviceEui, params)
+}
@@ -0,0 +1,146 @@
+package gonlt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/douglaszuqueto/gonlt/credentials"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+	"github.com/vingarcia/krest"
+)
+
+// recordedRequest is the part of a request checked by the tests
+type recordedRequest struct {
+	Method string
+	Path   string
+	Auth   string
+}
+
+// downlinkServer records the last request and answers with status
+func downlinkServer(t *testing.T, status int, body string) (*httptest.Server, *recordedRequest, *nlttypes.DownlinkRequest) {
+	t.Helper()
+
+	var (
+		got     recordedRequest
+		payload nlttypes.DownlinkRequest
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = recordedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Auth:   r.Header.Get("Authorization"),
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("request body is not JSON: %v", err)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &got, &payload
+}
+
+func TestDownlinkSend(t *testing.T) {
+	srv, got, payload := downlinkServer(t, http.StatusOK, `{"type":"downlink_request"}`)
+
+	s := NewDownlinkService(krest.New(defaultTimeout), credentials.NewTokenStore("token"), WithBaseURL(srv.URL))
+
+	resp, err := s.Send(context.Background(), "0011223344556677", nlttypes.DownlinkRequest{
+		Payload:   "AQI=",
+		Port:      10,
+		Confirmed: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.Method != http.MethodPost {
+		t.Errorf("method = %s, want POST", got.Method)
+	}
+
+	if want := "/messages/0011223344556677/send-downlink-claim"; got.Path != want {
+		t.Errorf("path = %s, want %s", got.Path, want)
+	}
+
+	if auth := got.Auth; auth != "Bearer token" {
+		t.Errorf("Authorization = %q, want %q", auth, "Bearer token")
+	}
+
+	if want := (nlttypes.DownlinkRequest{Payload: "AQI=", Port: 10, Confirmed: true}); *payload != want {
+		t.Errorf("body = %+v, want %+v", *payload, want)
+	}
+
+	if resp.Type != "downlink_request" {
+		t.Errorf("response type = %q, want downlink_request", resp.Type)
+	}
+}
+
+func TestNewClientPopulatesDownlink(t *testing.T) {
+	srv, got, _ := downlinkServer(t, http.StatusOK, `{}`)
+
+	client, err := NewClient(credentials.StaticToken("token"), WithBaseURL(srv.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Stop()
+
+	if client.Downlink.cfg != client.cfg {
+		t.Fatal("Client.Downlink is not wired to the client")
+	}
+
+	if _, err := client.Downlink.SendBytes(context.Background(), "0011223344556677", 1, []byte{0x01}, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "/messages/0011223344556677/send-downlink-claim"; got.Path != want {
+		t.Errorf("path = %s, want %s", got.Path, want)
+	}
+}
+
+func TestDownlinkSendAPIErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		kind   error
+	}{
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusUnprocessableEntity, ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			srv, _, _ := downlinkServer(t, tt.status, `{"detail":"nope"}`)
+
+			s := NewDownlinkService(krest.New(defaultTimeout), credentials.NewTokenStore("token"),
+				WithBaseURL(srv.URL), WithMaxRetries(1))
+
+			_, err := s.Send(context.Background(), "0011223344556677", nlttypes.DownlinkRequest{Payload: "AQI=", Port: 1})
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("error %v (%T) is not an *APIError", err, err)
+			}
+
+			if apiErr.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", apiErr.StatusCode, tt.status)
+			}
+
+			if apiErr.Detail != "nope" {
+				t.Errorf("detail = %q, want %q", apiErr.Detail, "nope")
+			}
+
+			if !errors.Is(err, tt.kind) {
+				t.Errorf("errors.Is(%v, %v) = false", err, tt.kind)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package gonlt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// QueueItem is a downlink waiting to be sent by a DownlinkQueue
+type QueueItem struct {
+	ID         string                   `json:"id"`
+	DevEui     string                   `json:"dev_eui"`
+	Request    nlttypes.DownlinkRequest `json:"request"`
+	EnqueuedAt time.Time                `json:"enqueued_at"`
+}
+
+// QueueOutcome reports the result of sending a queued downlink
+type QueueOutcome struct {
+	Item     QueueItem
+	Response *nlttypes.DownlinkResponse
+	Err      error
+}
+
+// QueueStore persists the pending items of a DownlinkQueue
+type QueueStore interface {
+	Load() ([]QueueItem, error)
+	Save(items []QueueItem) error
+}
+
+// DownlinkQueueConfig configures a DownlinkQueue, zero values disable the limits
+type DownlinkQueueConfig struct {
+	// DeviceInterval is the minimum time between two downlinks to the same device
+	DeviceInterval time.Duration
+
+	// GlobalInterval is the minimum time between any two downlinks
+	GlobalInterval time.Duration
+
+	// Store persists the pending items, optional
+	Store QueueStore
+
+	// OutcomeBuffer is the capacity of the outcomes channel
+	OutcomeBuffer int
+}
+
+// DownlinkQueue sends downlinks in FIFO order per device while enforcing
+// per device and global send rates. Identical downlinks waiting for the
+// same device are only queued once.
+type DownlinkQueue struct {
+	sender DownlinkService
+	cfg    DownlinkQueueConfig
+
+	mu       sync.Mutex
+	pending  map[string][]QueueItem
+	lastSent map[string]time.Time
+	lastAny  time.Time
+	seq      uint64
+
+	// inFlight maps a device to the ID of the item being sent to it
+	inFlight map[string]string
+
+	wake     chan struct{}
+	outcomes chan QueueOutcome
+}
+
+// NewDownlinkQueue creates a queue sending through sender, the pending items
+// of the store are loaded back into the queue
+func NewDownlinkQueue(sender DownlinkService, cfg DownlinkQueueConfig) (*DownlinkQueue, error) {
+	q := &DownlinkQueue{
+		sender:   sender,
+		cfg:      cfg,
+		pending:  map[string][]QueueItem{},
+		lastSent: map[string]time.Time{},
+		inFlight: map[string]string{},
+		wake:     make(chan struct{}, 1),
+		outcomes: make(chan QueueOutcome, cfg.OutcomeBuffer),
+	}
+
+	if cfg.Store != nil {
+		items, err := cfg.Store.Load()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			q.pending[item.DevEui] = append(q.pending[item.DevEui], item)
+		}
+	}
+
+	return q, nil
+}
+
+// Outcomes returns the channel where the result of every send is reported,
+// it must be drained while the queue runs
+func (q *DownlinkQueue) Outcomes() <-chan QueueOutcome {
+	return q.outcomes
+}
+
+// Enqueue adds a downlink to the device queue. When an identical downlink is
+// already waiting it returns the waiting item and false, a downlink being
+// sent doesn't count since it's already on its way.
+func (q *DownlinkQueue) Enqueue(devEui string, req nlttypes.DownlinkRequest) (QueueItem, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for _, item := range q.pending[devEui] {
+		if item.ID == q.inFlight[devEui] {
+			continue
+		}
+
+		if item.Request == req {
+			return item, false, nil
+		}
+	}
+
+	q.seq++
+
+	item := QueueItem{
+		ID:         fmt.Sprintf("%d-%d", time.Now().UnixNano(), q.seq),
+		DevEui:     devEui,
+		Request:    req,
+		EnqueuedAt: time.Now(),
+	}
+
+	q.pending[devEui] = append(q.pending[devEui], item)
+
+	if err := q.save(); err != nil {
+		q.pending[devEui] = q.pending[devEui][:len(q.pending[devEui])-1]
+		return QueueItem{}, false, err
+	}
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+
+	return item, true, nil
+}
+
+// Pending returns how many downlinks are waiting for the device
+func (q *DownlinkQueue) Pending(devEui string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return len(q.pending[devEui])
+}
+
+// Run sends the queued downlinks until the context is canceled. Several Run
+// calls may share a queue, a device never has two downlinks in flight.
+func (q *DownlinkQueue) Run(ctx context.Context) error {
+	for {
+		item, wait, ok := q.next(time.Now())
+		if !ok {
+			var timer *time.Timer
+			var ready <-chan time.Time
+			if wait > 0 {
+				timer = time.NewTimer(wait)
+				ready = timer.C
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-q.wake:
+			case <-ready:
+			}
+
+			if timer != nil {
+				timer.Stop()
+			}
+
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			continue
+		}
+
+		resp, err := q.sender.Send(ctx, item.DevEui, item.Request)
+		if err != nil && ctx.Err() != nil {
+			// keep the item so it's sent again by the next Run
+			q.release(item)
+			return ctx.Err()
+		}
+
+		if saveErr := q.done(item); saveErr != nil && err == nil {
+			err = saveErr
+		}
+
+		select {
+		case q.outcomes <- QueueOutcome{Item: item, Response: resp, Err: err}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// next returns the oldest item ready to be sent, or how long to wait for one.
+// A zero wait means the queue is empty.
+func (q *DownlinkQueue) next(now time.Time) (QueueItem, time.Duration, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var (
+		best    QueueItem
+		found   bool
+		minWait time.Duration
+	)
+
+	globalWait := q.lastAny.Add(q.cfg.GlobalInterval).Sub(now)
+
+	for devEui, items := range q.pending {
+		if len(items) == 0 || q.inFlight[devEui] != "" {
+			continue
+		}
+
+		wait := q.lastSent[devEui].Add(q.cfg.DeviceInterval).Sub(now)
+		if wait < globalWait {
+			wait = globalWait
+		}
+
+		if wait > 0 {
+			if minWait == 0 || wait < minWait {
+				minWait = wait
+			}
+
+			continue
+		}
+
+		if !found || items[0].EnqueuedAt.Before(best.EnqueuedAt) {
+			best = items[0]
+			found = true
+		}
+	}
+
+	if found {
+		q.lastSent[best.DevEui] = now
+		q.lastAny = now
+		q.inFlight[best.DevEui] = best.ID
+	}
+
+	return best, minWait, found
+}
+
+// done removes the sent item from its device queue
+func (q *DownlinkQueue) done(item QueueItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.inFlight, item.DevEui)
+
+	items := q.pending[item.DevEui]
+	if len(items) > 0 && items[0].ID == item.ID {
+		items = items[1:]
+	}
+
+	if len(items) == 0 {
+		delete(q.pending, item.DevEui)
+	} else {
+		q.pending[item.DevEui] = items
+
+		// another Run may be waiting for the device to be free
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+
+	return q.save()
+}
+
+// release puts back an item whose send was interrupted
+func (q *DownlinkQueue) release(item QueueItem) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	delete(q.inFlight, item.DevEui)
+}
+
+// save must be called with mu held
+func (q *DownlinkQueue) save() error {
+	if q.cfg.Store == nil {
+		return nil
+	}
+
+	var items []QueueItem
+	for _, pending := range q.pending {
+		items = append(items, pending...)
+	}
+
+	return q.cfg.Store.Save(items)
+}
+
+// FileQueueStore is a QueueStore backed by a JSON file only readable by its owner
+type FileQueueStore struct {
+	path string
+}
+
+var _ QueueStore = &FileQueueStore{}
+
+// NewFileQueueStore creates a store at path, the file is created on the first Save
+func NewFileQueueStore(path string) *FileQueueStore {
+	return &FileQueueStore{
+		path: path,
+	}
+}
+
+func (s *FileQueueStore) Load() ([]QueueItem, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var items []QueueItem
+
+	err = json.Unmarshal(data, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (s *FileQueueStore) Save(items []QueueItem) error {
+	data, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data)
+}
@@ -0,0 +1,331 @@
+package gonlt
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+type sentDownlink struct {
+	devEui  string
+	payload string
+	at      time.Time
+}
+
+// queueSender records the downlinks sent by a DownlinkQueue, a non nil gate
+// blocks every Send until it receives a value
+type queueSender struct {
+	mu   sync.Mutex
+	sent []sentDownlink
+	gate chan struct{}
+}
+
+func (s *queueSender) Send(ctx context.Context, deviceEui string, params nlttypes.DownlinkRequest) (*nlttypes.DownlinkResponse, error) {
+	if s.gate != nil {
+		select {
+		case <-s.gate:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, sentDownlink{devEui: deviceEui, payload: params.Payload, at: time.Now()})
+
+	return &nlttypes.DownlinkResponse{}, nil
+}
+
+func (s *queueSender) SendBytes(ctx context.Context, deviceEui string, port int, payload []byte, confirmed bool) (*nlttypes.DownlinkResponse, error) {
+	panic("not used by the queue")
+}
+
+func (s *queueSender) SendAndWait(ctx context.Context, deviceEui string, params nlttypes.DownlinkRequest, timeout time.Duration) (*DownlinkResult, error) {
+	panic("not used by the queue")
+}
+
+func (s *queueSender) downlinks() []sentDownlink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]sentDownlink(nil), s.sent...)
+}
+
+// runQueue runs the queue with runners Run calls and collects n outcomes
+func runQueue(t *testing.T, q *DownlinkQueue, runners, n int) []QueueOutcome {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < runners; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.Run(ctx)
+		}()
+	}
+
+	var outcomes []QueueOutcome
+	for len(outcomes) < n {
+		select {
+		case outcome := <-q.Outcomes():
+			if outcome.Err != nil {
+				t.Errorf("send %s: %v", outcome.Item.ID, outcome.Err)
+			}
+			outcomes = append(outcomes, outcome)
+		case <-ctx.Done():
+			t.Fatalf("got %d outcomes, want %d", len(outcomes), n)
+		}
+	}
+
+	cancel()
+	wg.Wait()
+
+	return outcomes
+}
+
+func enqueue(t *testing.T, q *DownlinkQueue, devEui, payload string) {
+	t.Helper()
+
+	_, added, err := q.Enqueue(devEui, nlttypes.DownlinkRequest{Payload: payload, Port: 1})
+	if err != nil {
+		t.Fatalf("enqueue %s: %v", payload, err)
+	}
+	if !added {
+		t.Fatalf("enqueue %s: not added", payload)
+	}
+}
+
+func TestDownlinkQueueDeviceFIFO(t *testing.T) {
+	sender := &queueSender{}
+
+	q, err := NewDownlinkQueue(sender, DownlinkQueueConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, payload := range []string{"a1", "b1", "a2", "b2", "a3"} {
+		enqueue(t, q, "AA"+payload[:1], payload)
+	}
+
+	runQueue(t, q, 3, 5)
+
+	got := map[string][]string{}
+	for _, sent := range sender.downlinks() {
+		got[sent.devEui] = append(got[sent.devEui], sent.payload)
+	}
+
+	want := map[string][]string{
+		"AAa": {"a1", "a2", "a3"},
+		"AAb": {"b1", "b2"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if n := q.Pending("AAa") + q.Pending("AAb"); n != 0 {
+		t.Errorf("%d downlinks left in the queue", n)
+	}
+}
+
+func TestDownlinkQueueDedup(t *testing.T) {
+	sender := &queueSender{gate: make(chan struct{})}
+
+	q, err := NewDownlinkQueue(sender, DownlinkQueueConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := nlttypes.DownlinkRequest{Payload: "AQ==", Port: 1}
+
+	first, added, err := q.Enqueue("AA01", req)
+	if err != nil || !added {
+		t.Fatalf("first enqueue: added %v, err %v", added, err)
+	}
+
+	dup, added, err := q.Enqueue("AA01", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added || dup.ID != first.ID {
+		t.Fatalf("duplicate: added %v, id %s, want the waiting item %s", added, dup.ID, first.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.Run(ctx)
+	}()
+
+	// wait for the first item to be in flight
+	for {
+		q.mu.Lock()
+		inFlight := q.inFlight["AA01"]
+		q.mu.Unlock()
+
+		if inFlight == first.ID {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("the first downlink was never sent")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	second, added, err := q.Enqueue("AA01", req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !added || second.ID == first.ID {
+		t.Fatalf("enqueue while in flight: added %v, id %s", added, second.ID)
+	}
+
+	if _, added, _ := q.Enqueue("AA01", req); added {
+		t.Fatal("the second copy was queued twice")
+	}
+
+	for i := 0; i < 2; i++ {
+		sender.gate <- struct{}{}
+
+		outcome := <-q.Outcomes()
+		if want := []string{first.ID, second.ID}[i]; outcome.Item.ID != want {
+			t.Errorf("outcome %d: got %s, want %s", i, outcome.Item.ID, want)
+		}
+	}
+
+	cancel()
+	<-done
+
+	if n := len(sender.downlinks()); n != 2 {
+		t.Errorf("got %d sends, want 2", n)
+	}
+}
+
+func TestDownlinkQueueIntervals(t *testing.T) {
+	const (
+		deviceInterval = 60 * time.Millisecond
+		globalInterval = 20 * time.Millisecond
+	)
+
+	sender := &queueSender{}
+
+	q, err := NewDownlinkQueue(sender, DownlinkQueueConfig{
+		DeviceInterval: deviceInterval,
+		GlobalInterval: globalInterval,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, devEui := range []string{"AA01", "AA02", "AA01", "AA02"} {
+		enqueue(t, q, devEui, devEui+"-"+time.Now().String())
+	}
+
+	runQueue(t, q, 2, 4)
+
+	sent := sender.downlinks()
+	lastByDevice := map[string]time.Time{}
+
+	for i, s := range sent {
+		if i > 0 {
+			if gap := s.at.Sub(sent[i-1].at); gap < globalInterval {
+				t.Errorf("send %d: %v after the previous one, want at least %v", i, gap, globalInterval)
+			}
+		}
+
+		if last, ok := lastByDevice[s.devEui]; ok {
+			if gap := s.at.Sub(last); gap < deviceInterval {
+				t.Errorf("send %d: %v after the previous one to %s, want at least %v", i, gap, s.devEui, deviceInterval)
+			}
+		}
+
+		lastByDevice[s.devEui] = s.at
+	}
+}
+
+func TestDownlinkQueueConcurrentRuns(t *testing.T) {
+	sender := &queueSender{}
+
+	q, err := NewDownlinkQueue(sender, DownlinkQueueConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 20
+	for i := 0; i < n; i++ {
+		enqueue(t, q, "AA01", string(rune('a'+i)))
+	}
+
+	outcomes := runQueue(t, q, 4, n)
+
+	seen := map[string]bool{}
+	for _, outcome := range outcomes {
+		if seen[outcome.Item.ID] {
+			t.Errorf("%s sent twice", outcome.Item.ID)
+		}
+		seen[outcome.Item.ID] = true
+	}
+
+	if got := len(sender.downlinks()); got != n {
+		t.Errorf("got %d sends, want %d", got, n)
+	}
+}
+
+func TestDownlinkQueueReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queue.json")
+
+	q, err := NewDownlinkQueue(&queueSender{}, DownlinkQueueConfig{Store: NewFileQueueStore(path)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, payload := range []string{"a1", "b1", "a2"} {
+		enqueue(t, q, "AA"+payload[:1], payload)
+	}
+
+	sender := &queueSender{}
+
+	reloaded, err := NewDownlinkQueue(sender, DownlinkQueueConfig{Store: NewFileQueueStore(path)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := reloaded.Pending("AAa"); got != 2 {
+		t.Errorf("AAa: got %d pending, want 2", got)
+	}
+	if got := reloaded.Pending("AAb"); got != 1 {
+		t.Errorf("AAb: got %d pending, want 1", got)
+	}
+
+	runQueue(t, reloaded, 1, 3)
+
+	var got []string
+	for _, sent := range sender.downlinks() {
+		got = append(got, sent.payload)
+	}
+
+	if want := []string{"a1", "b1", "a2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	items, err := NewFileQueueStore(path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 0 {
+		t.Errorf("%d items left in the store", len(items))
+	}
+}
@@ -0,0 +1,152 @@
+package gonlt
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// ackPollInterval is how often the messages are checked while waiting for an ack
+const ackPollInterval = 5 * time.Second
+
+// ErrUnconfirmedDownlink is returned by SendAndWait for downlinks that can't be acked
+var ErrUnconfirmedDownlink = errors.New("gonlt: only confirmed downlinks can be waited")
+
+// DownlinkStatus is the outcome of a confirmed downlink
+type DownlinkStatus string
+
+const (
+	// DownlinkDelivered means the device acked the downlink
+	DownlinkDelivered DownlinkStatus = "delivered"
+
+	// DownlinkExpired means no ack was seen before the timeout
+	DownlinkExpired DownlinkStatus = "expired"
+
+	// DownlinkFailed means the network reported an error or a newer
+	// downlink was sent before the ack
+	DownlinkFailed DownlinkStatus = "failed"
+)
+
+// DownlinkResult describes a confirmed downlink after waiting for its ack
+type DownlinkResult struct {
+	Status   DownlinkStatus
+	Response *nlttypes.DownlinkResponse
+
+	// Message that settled the status, nil when expired
+	Message *nlttypes.Message
+}
+
+// SendAndWait sends a confirmed downlink and polls the device messages, right
+// away and then every 5s or half the timeout if shorter, until it is acked,
+// the network reports a failure or the timeout expires
+func (s DownlinkServiceOp) SendAndWait(ctx context.Context, deviceEui string, params nlttypes.DownlinkRequest, timeout time.Duration) (*DownlinkResult, error) {
+	if !params.Confirmed {
+		return nil, ErrUnconfirmedDownlink
+	}
+
+	sentAt := time.Now()
+
+	resp, err := s.Send(ctx, deviceEui, params)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Meta.Time > 0 {
+		sentAt = unixTime(resp.Meta.Time)
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// a timeout shorter than the poll interval still gets several polls
+	interval := ackPollInterval
+	if half := timeout / 2; half < interval {
+		interval = half
+	}
+
+	for {
+		messages, err := s.messages.List(waitCtx, deviceEui, MessageFilter{
+			StartDate: sentAt.Add(-time.Minute),
+			EndDate:   time.Now().Add(time.Minute),
+		})
+		if err != nil {
+			if waitCtx.Err() == nil {
+				s.cfg.logger.Printf("gonlt: waiting downlink ack: %v", err)
+			}
+		} else if result, ok := settleDownlink(resp, sentAt, messages.Messages); ok {
+			return result, nil
+		}
+
+		timer := time.NewTimer(interval)
+
+		select {
+		case <-waitCtx.Done():
+			timer.Stop()
+
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			return &DownlinkResult{
+				Status:   DownlinkExpired,
+				Response: resp,
+			}, nil
+		case <-timer.C:
+		}
+	}
+}
+
+// settleDownlink looks for the first message after the downlink that tells its outcome
+func settleDownlink(resp *nlttypes.DownlinkResponse, sentAt time.Time, messages []nlttypes.Message) (*DownlinkResult, bool) {
+	sorted := make([]nlttypes.Message, len(messages))
+	copy(sorted, messages)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Meta.Time < sorted[j].Meta.Time
+	})
+
+	for i := range sorted {
+		msg := &sorted[i]
+
+		if unixTime(msg.Meta.Time).Before(sentAt) {
+			continue
+		}
+
+		status := DownlinkStatus("")
+
+		switch msg.Type {
+		case nlttypes.MessageError:
+			if resp.Meta.PacketID != "" && msg.Meta.PacketID == resp.Meta.PacketID {
+				status = DownlinkFailed
+			}
+		case nlttypes.MessageDownlink:
+			if msg.Params.CounterDown > resp.Params.CounterDown && resp.Params.CounterDown > 0 {
+				status = DownlinkFailed
+			}
+		case nlttypes.MessageUplink:
+			if msg.Params.Ack {
+				status = DownlinkDelivered
+			}
+		}
+
+		if status != "" {
+			return &DownlinkResult{
+				Status:   status,
+				Response: resp,
+				Message:  msg,
+			}, true
+		}
+	}
+
+	return nil, false
+}
+
+// unixTime converts the fractional unix timestamps used by the API
+func unixTime(t float64) time.Time {
+	sec := int64(t)
+
+	return time.Unix(sec, int64((t-float64(sec))*float64(time.Second)))
+}
@@ -0,0 +1,156 @@
+package gonlt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/douglaszuqueto/gonlt/credentials"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+	"github.com/vingarcia/krest"
+)
+
+func testMessage(typ nlttypes.MessageType, at float64, set func(*nlttypes.Message)) nlttypes.Message {
+	var msg nlttypes.Message
+
+	msg.Type = typ
+	msg.Meta.Time = at
+
+	if set != nil {
+		set(&msg)
+	}
+
+	return msg
+}
+
+func TestSettleDownlink(t *testing.T) {
+	const sent = 1700000000.0
+
+	resp := &nlttypes.DownlinkResponse{}
+	resp.Meta.PacketID = "p1"
+	resp.Params.CounterDown = 7
+
+	ack := func(m *nlttypes.Message) { m.Params.Ack = true }
+
+	tests := []struct {
+		name     string
+		messages []nlttypes.Message
+		want     DownlinkStatus
+		wantAt   float64
+	}{
+		{
+			name: "no messages",
+		},
+		{
+			name:     "ack before the downlink is ignored",
+			messages: []nlttypes.Message{testMessage(nlttypes.MessageUplink, sent-10, ack)},
+		},
+		{
+			name:     "uplink without ack",
+			messages: []nlttypes.Message{testMessage(nlttypes.MessageUplink, sent+10, nil)},
+		},
+		{
+			name:     "acked",
+			messages: []nlttypes.Message{testMessage(nlttypes.MessageUplink, sent+10, ack)},
+			want:     DownlinkDelivered,
+			wantAt:   sent + 10,
+		},
+		{
+			name: "error of the downlink packet",
+			messages: []nlttypes.Message{testMessage(nlttypes.MessageError, sent+5, func(m *nlttypes.Message) {
+				m.Meta.PacketID = "p1"
+			})},
+			want:   DownlinkFailed,
+			wantAt: sent + 5,
+		},
+		{
+			name: "error of another packet",
+			messages: []nlttypes.Message{testMessage(nlttypes.MessageError, sent+5, func(m *nlttypes.Message) {
+				m.Meta.PacketID = "p2"
+			})},
+		},
+		{
+			name: "newer downlink before the ack",
+			messages: []nlttypes.Message{testMessage(nlttypes.MessageDownlink, sent+5, func(m *nlttypes.Message) {
+				m.Params.CounterDown = 8
+			})},
+			want:   DownlinkFailed,
+			wantAt: sent + 5,
+		},
+		{
+			name: "the downlink itself",
+			messages: []nlttypes.Message{testMessage(nlttypes.MessageDownlink, sent+1, func(m *nlttypes.Message) {
+				m.Params.CounterDown = 7
+			})},
+		},
+		{
+			name: "earliest outcome wins whatever the order",
+			messages: []nlttypes.Message{
+				testMessage(nlttypes.MessageUplink, sent+30, ack),
+				testMessage(nlttypes.MessageError, sent+20, func(m *nlttypes.Message) { m.Meta.PacketID = "p1" }),
+			},
+			want:   DownlinkFailed,
+			wantAt: sent + 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, ok := settleDownlink(resp, unixTime(sent), tt.messages)
+
+			if tt.want == "" {
+				if ok {
+					t.Fatalf("settled as %s, want unsettled", result.Status)
+				}
+
+				return
+			}
+
+			if !ok {
+				t.Fatalf("unsettled, want %s", tt.want)
+			}
+
+			if result.Status != tt.want {
+				t.Errorf("status = %s, want %s", result.Status, tt.want)
+			}
+
+			if result.Message == nil || result.Message.Meta.Time != tt.wantAt {
+				t.Errorf("settled by %+v, want the message at %v", result.Message, tt.wantAt)
+			}
+		})
+	}
+}
+
+func TestSendAndWaitPollsBeforeShortTimeout(t *testing.T) {
+	polls := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/send-downlink-claim") {
+			_, _ = w.Write([]byte(`{"type":"downlink_request","meta":{"time":1700000000}}`))
+			return
+		}
+
+		polls++
+		_, _ = w.Write([]byte(`{"messages":[{"type":"uplink","meta":{"time":1700000001},"params":{"ack":true}}]}`))
+	}))
+	defer srv.Close()
+
+	s := NewDownlinkService(krest.New(defaultTimeout), credentials.NewTokenStore("token"), WithBaseURL(srv.URL))
+
+	result, err := s.SendAndWait(context.Background(), "0011223344556677",
+		nlttypes.DownlinkRequest{Payload: "AQI=", Port: 1, Confirmed: true}, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Status != DownlinkDelivered {
+		t.Errorf("status = %s, want %s", result.Status, DownlinkDelivered)
+	}
+
+	if polls != 1 {
+		t.Errorf("polled %d times, want 1", polls)
+	}
+}
@@ -2,59 +2,134 @@ package gonlt
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
 
-	"github.com/douglaszuqueto/gonlt/nlttypes"
+	"github.com/vingarcia/krest"
 )
 
+// Sentinel errors, use them with errors.Is to branch on API failures
+var (
+	ErrUnauthorized = errors.New("gonlt: unauthorized")
+	ErrNotFound     = errors.New("gonlt: not found")
+	ErrValidation   = errors.New("gonlt: validation failed")
+	ErrRateLimited  = errors.New("gonlt: rate limited")
+)
+
+// APIError is returned when the NLT API answers with a non 2xx status code
+type APIError struct {
+	StatusCode int
+	Method     string
+	Endpoint   string
+	Body       []byte
+
+	// Detail is the message parsed from the "detail" field of the body
+	Detail string
+
+	kind error
+}
+
+func (e *APIError) Error() string {
+	msg := e.Detail
+	if msg == "" {
+		msg = strings.TrimSpace(string(e.Body))
+	}
+
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+
+	return fmt.Sprintf("gonlt: %s %s: %d: %s", e.Method, e.Endpoint, e.StatusCode, msg)
+}
+
+// Is reports whether the error matches one of the sentinel errors
+func (e *APIError) Is(target error) bool {
+	return e.kind != nil && e.kind == target
+}
+
 // handle errors
-func handleError(body []byte, statusCode int) error {
+func handleError(method, endpoint string, resp krest.Response, err error) error {
+	// the request never reached the server
+	if resp.StatusCode == 0 {
+		return err
+	}
+
+	return newAPIError(method, endpoint, resp.StatusCode, resp.Body)
+}
+
+func newAPIError(method, endpoint string, statusCode int, body []byte) *APIError {
+	return &APIError{
+		StatusCode: statusCode,
+		Method:     method,
+		Endpoint:   endpoint,
+		Body:       body,
+		Detail:     parseDetail(body),
+		kind:       errorKind(statusCode),
+	}
+}
+
+// errorKind maps a status code to its sentinel error
+func errorKind(statusCode int) error {
 	switch statusCode {
-	case 401:
-		return handleUnauthorizedError(body)
-	case 403:
-		return handleAuthError(body)
-	case 404:
-		return fmt.Errorf("not found")
-	case 422:
-		return handleDeviceCreateError(body)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrValidation
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
 	default:
-		return fmt.Errorf("unknown error: %s", body)
+		return nil
 	}
 }
 
-// handle unauthorized errors
-func handleUnauthorizedError(body []byte) error {
-	var errResp nlttypes.AuthError
+// parseDetail extracts the "detail" field of an error body, which may be a
+// plain string or a list of validation errors
+func parseDetail(body []byte) string {
+	var errResp struct {
+		Detail  json.RawMessage `json:"detail"`
+		Message string          `json:"message"`
+	}
 
-	err := json.Unmarshal(body, &errResp)
-	if err != nil {
-		return err
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return ""
 	}
 
-	return fmt.Errorf("invalid token: %s", errResp.Detail)
-}
+	if len(errResp.Detail) == 0 {
+		return errResp.Message
+	}
 
-// handle auth errors
-func handleAuthError(body []byte) error {
-	var errResp nlttypes.AuthError
+	var detail string
+	if err := json.Unmarshal(errResp.Detail, &detail); err == nil {
+		return detail
+	}
 
-	err := json.Unmarshal(body, &errResp)
-	if err != nil {
-		return err
+	var details []struct {
+		Loc []interface{} `json:"loc"`
+		Msg string        `json:"msg"`
 	}
+	if err := json.Unmarshal(errResp.Detail, &details); err == nil {
+		msgs := make([]string, 0, len(details))
 
-	return fmt.Errorf("invalid credentials: %s", errResp.Detail)
-}
+		for _, d := range details {
+			loc := make([]string, 0, len(d.Loc))
+			for _, l := range d.Loc {
+				loc = append(loc, fmt.Sprint(l))
+			}
 
-// handle device errors
-func handleDeviceCreateError(body []byte) error {
-	var errResp nlttypes.DeviceCreateError
+			if len(loc) > 0 {
+				msgs = append(msgs, fmt.Sprintf("%s: %s", strings.Join(loc, "."), d.Msg))
+				continue
+			}
 
-	err := json.Unmarshal(body, &errResp)
-	if err != nil {
-		return err
+			msgs = append(msgs, d.Msg)
+		}
+
+		return strings.Join(msgs, "; ")
 	}
 
-	return fmt.Errorf("device: %s", errResp.Detail)
+	return string(errResp.Detail)
 }
@@ -0,0 +1,311 @@
+// Package fleet reconciles the devices of a NLT account with a desired list,
+// e.g. an inventory kept in git
+package fleet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/douglaszuqueto/gonlt"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+const defaultConcurrency = 4
+
+// ErrSkipped is reported for the steps of a device after one of them failed
+var ErrSkipped = errors.New("fleet: skipped after a previous step failed")
+
+// Options configures a Syncer, zero values use the defaults
+type Options struct {
+	// Selector restricts the devices managed by the fleet, devices missing
+	// from the desired list are only deactivated or deleted when they
+	// match. Without a selector they are left alone unless ManageAll is set.
+	Selector *gonlt.DeviceFilter
+
+	// ManageAll manages every device of the account when Selector is nil,
+	// so any device missing from the desired list is deactivated or deleted
+	ManageAll bool
+
+	// AllowEmpty accepts an empty desired list, which otherwise fails with
+	// gonlt.ErrValidation since it would remove every managed device, e.g.
+	// after an inventory that failed to parse
+	AllowEmpty bool
+
+	// Prune deletes the managed devices missing from the desired list
+	// instead of deactivating them
+	Prune bool
+
+	// Concurrency is the maximum number of devices changed at once, defaults to 4
+	Concurrency int
+
+	// DryRun makes Sync print the plan to Output instead of applying it
+	DryRun bool
+
+	// Output receives the plan of dry runs, nothing is printed when nil
+	Output io.Writer
+}
+
+// Syncer plans and applies the changes reconciling the devices of the account
+type Syncer struct {
+	devices gonlt.DeviceService
+	opts    Options
+}
+
+// NewSyncer creates a syncer managing the devices of the service
+func NewSyncer(devices gonlt.DeviceService, opts Options) *Syncer {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultConcurrency
+	}
+
+	return &Syncer{
+		devices: devices,
+		opts:    opts,
+	}
+}
+
+// Plan lists the devices of the account and computes the steps reconciling
+// them with the desired devices
+func (s *Syncer) Plan(ctx context.Context, desired []nlttypes.DeviceCreateRequest) (Plan, error) {
+	current, err := s.devices.All(ctx)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	return s.Compute(desired, current)
+}
+
+// Compute returns the steps turning the current devices into the desired
+// ones. Missing devices are created and activated, existing ones are
+// updated and activated when needed, managed devices missing from the list are
+// deactivated, or deleted with Prune. An empty desired list is an error
+// unless AllowEmpty is set.
+func (s *Syncer) Compute(desired []nlttypes.DeviceCreateRequest, current []nlttypes.Device) (Plan, error) {
+	var plan Plan
+
+	if len(desired) == 0 && !s.opts.AllowEmpty {
+		return Plan{}, fmt.Errorf("%w: empty desired device list, set AllowEmpty to remove every managed device", gonlt.ErrValidation)
+	}
+
+	existing := make(map[string]nlttypes.Device, len(current))
+	for _, device := range current {
+		existing[euiKey(device.DevEui)] = device
+	}
+
+	wanted := make(map[string]bool, len(desired))
+
+	for i := range desired {
+		want := desired[i]
+
+		key := euiKey(want.DevEui)
+		if key == "" {
+			return Plan{}, fmt.Errorf("%w: desired device %d has no dev_eui", gonlt.ErrValidation, i)
+		}
+
+		if wanted[key] {
+			return Plan{}, fmt.Errorf("%w: device %s is desired twice", gonlt.ErrValidation, want.DevEui)
+		}
+
+		wanted[key] = true
+
+		have, ok := existing[key]
+		if !ok {
+			plan.Steps = append(plan.Steps,
+				Step{Action: Create, DevEui: want.DevEui, Device: &want},
+				Step{Action: Activate, DevEui: want.DevEui},
+			)
+			continue
+		}
+
+		// address the device as the API knows it
+		want.DevEui = have.DevEui
+
+		if changes := diffDevice(want, have); len(changes) > 0 {
+			plan.Steps = append(plan.Steps, Step{Action: Update, DevEui: have.DevEui, Changes: changes, Device: &want})
+		}
+
+		if !active(have) {
+			plan.Steps = append(plan.Steps, Step{Action: Activate, DevEui: have.DevEui})
+		}
+	}
+
+	for _, have := range current {
+		if wanted[euiKey(have.DevEui)] || !s.managed(have) {
+			continue
+		}
+
+		switch {
+		case s.opts.Prune:
+			plan.Steps = append(plan.Steps, Step{Action: Delete, DevEui: have.DevEui})
+		case active(have):
+			plan.Steps = append(plan.Steps, Step{Action: Deactivate, DevEui: have.DevEui})
+		}
+	}
+
+	return plan, nil
+}
+
+// Result is the outcome of a step
+type Result struct {
+	Step
+	Err error
+}
+
+// Report lists the outcome of every step of an applied plan, in plan order
+type Report []Result
+
+// Failed returns the results of the failed or skipped steps
+func (r Report) Failed() Report {
+	var failed Report
+
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+
+	return failed
+}
+
+// Err returns the first error of each failed device as gonlt.DeviceErrors,
+// nil when every step succeeded
+func (r Report) Err() error {
+	errs := gonlt.DeviceErrors{}
+
+	for _, result := range r {
+		if result.Err == nil {
+			continue
+		}
+
+		if _, ok := errs[result.DevEui]; !ok {
+			errs[result.DevEui] = fmt.Errorf("%s: %w", result.Action, result.Err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// Apply runs the steps of the plan changing at most opts.Concurrency devices
+// at once. A failed step skips the remaining steps of its device only.
+func (s *Syncer) Apply(ctx context.Context, plan Plan) Report {
+	report := make(Report, len(plan.Steps))
+
+	// group the steps of each device keeping their order
+	var order []string
+	byDevice := map[string][]int{}
+
+	for i, step := range plan.Steps {
+		report[i] = Result{Step: step}
+
+		key := euiKey(step.DevEui)
+		if _, ok := byDevice[key]; !ok {
+			order = append(order, key)
+		}
+
+		byDevice[key] = append(byDevice[key], i)
+	}
+
+	var wg sync.WaitGroup
+
+	sem := make(chan struct{}, s.opts.Concurrency)
+
+	for _, key := range order {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(steps []int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			var failed bool
+
+			for _, i := range steps {
+				if failed {
+					report[i].Err = ErrSkipped
+					continue
+				}
+
+				if err := s.apply(ctx, plan.Steps[i]); err != nil {
+					report[i].Err = err
+					failed = true
+				}
+			}
+		}(byDevice[key])
+	}
+
+	wg.Wait()
+
+	return report
+}
+
+// Sync plans and applies the changes, or only prints the plan with DryRun.
+// The error is the planning error or the Report error.
+func (s *Syncer) Sync(ctx context.Context, desired []nlttypes.DeviceCreateRequest) (Plan, Report, error) {
+	plan, err := s.Plan(ctx, desired)
+	if err != nil {
+		return Plan{}, nil, err
+	}
+
+	if s.opts.DryRun {
+		if s.opts.Output != nil {
+			if err := plan.Print(s.opts.Output); err != nil {
+				return plan, nil, err
+			}
+		}
+
+		return plan, nil, nil
+	}
+
+	report := s.Apply(ctx, plan)
+
+	return plan, report, report.Err()
+}
+
+func (s *Syncer) apply(ctx context.Context, step Step) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	switch step.Action {
+	case Create:
+		_, err := s.devices.Create(ctx, *step.Device)
+		return err
+	case Update:
+		_, err := s.devices.Update(ctx, nlttypes.DeviceUpdateRequest(*step.Device))
+		return err
+	case Activate:
+		return s.devices.Activate(ctx, step.DevEui)
+	case Deactivate:
+		return s.devices.Deactivate(ctx, step.DevEui)
+	case Delete:
+		return s.devices.Delete(ctx, step.DevEui)
+	default:
+		return fmt.Errorf("fleet: unknown action %q", step.Action)
+	}
+}
+
+// managed reports whether a device missing from the desired list may be changed
+func (s *Syncer) managed(device nlttypes.Device) bool {
+	if s.opts.Selector == nil {
+		return s.opts.ManageAll
+	}
+
+	return s.opts.Selector.Match(device)
+}
+
+func active(device nlttypes.Device) bool {
+	isActive := true
+
+	return gonlt.DeviceFilter{Active: &isActive}.Match(device)
+}
+
+func euiKey(devEui string) string {
+	return strings.ToLower(strings.TrimSpace(devEui))
+}
@@ -0,0 +1,291 @@
+package fleet
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/douglaszuqueto/gonlt"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// fakeDevices is an in memory DeviceService recording the changes
+type fakeDevices struct {
+	gonlt.DeviceService
+
+	mu      sync.Mutex
+	devices nlttypes.DeviceListResponse
+	calls   []string
+	fail    map[string]error
+}
+
+// record logs the call and, unless it is set to fail, applies change to the
+// device, which is nil when the account doesn't hold it
+func (f *fakeDevices) record(call, devEui string, change func(device *nlttypes.Device)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, call+" "+devEui)
+
+	if err := f.fail[call+" "+devEui]; err != nil {
+		return err
+	}
+
+	var device *nlttypes.Device
+	for i := range f.devices {
+		if euiKey(f.devices[i].DevEui) == euiKey(devEui) {
+			device = &f.devices[i]
+		}
+	}
+
+	change(device)
+
+	return nil
+}
+
+func (f *fakeDevices) All(ctx context.Context) (nlttypes.DeviceListResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append(nlttypes.DeviceListResponse(nil), f.devices...), nil
+}
+
+func (f *fakeDevices) Create(ctx context.Context, d nlttypes.DeviceCreateRequest) (*nlttypes.Device, error) {
+	return &nlttypes.Device{DevEui: d.DevEui}, f.record("create", d.DevEui, func(*nlttypes.Device) {
+		var device nlttypes.Device
+		copyFields(&device, d)
+
+		f.devices = append(f.devices, device)
+	})
+}
+
+func (f *fakeDevices) Update(ctx context.Context, d nlttypes.DeviceUpdateRequest) (*nlttypes.Device, error) {
+	return &nlttypes.Device{DevEui: d.DevEui}, f.record("update", d.DevEui, func(device *nlttypes.Device) {
+		copyFields(device, d)
+	})
+}
+
+func (f *fakeDevices) Activate(ctx context.Context, id string) error {
+	return f.record("activate", id, func(device *nlttypes.Device) {
+		device.ActivatedAt = "2024-02-01T00:00:00Z"
+	})
+}
+
+func (f *fakeDevices) Deactivate(ctx context.Context, id string) error {
+	return f.record("deactivate", id, func(device *nlttypes.Device) {
+		device.DeactivatedAt = "2024-03-01T00:00:00Z"
+	})
+}
+
+func (f *fakeDevices) Delete(ctx context.Context, id string) error {
+	return f.record("delete", id, func(*nlttypes.Device) {
+		for i := range f.devices {
+			if euiKey(f.devices[i].DevEui) == euiKey(id) {
+				f.devices = append(f.devices[:i], f.devices[i+1:]...)
+				return
+			}
+		}
+	})
+}
+
+// copyFields sets the fields of the device from a request, they share their JSON keys
+func copyFields(device *nlttypes.Device, request interface{}) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		panic(err)
+	}
+
+	if err := json.Unmarshal(data, device); err != nil {
+		panic(err)
+	}
+}
+
+const activatedAt = "2024-01-01T00:00:00Z"
+
+func account() nlttypes.DeviceListResponse {
+	return nlttypes.DeviceListResponse{
+		{DevEui: "AA01", Tags: []string{"fleet"}, Band: "LA915-928A", ActivatedAt: activatedAt},
+		{DevEui: "AA02", Tags: []string{"fleet"}, Band: "LA915-928A"},
+		{DevEui: "BB01", Tags: []string{"fleet"}, ActivatedAt: activatedAt},
+		{DevEui: "CC01", Tags: []string{"lab"}, ActivatedAt: activatedAt},
+	}
+}
+
+func desired() []nlttypes.DeviceCreateRequest {
+	return []nlttypes.DeviceCreateRequest{
+		{DevEui: "aa01", Tags: []string{"fleet"}, Band: "LA915-928A"},
+		{DevEui: "AA02", Tags: []string{"fleet", "new"}, Band: "LA915-928A"},
+		{DevEui: "DD01", Tags: []string{"fleet"}},
+	}
+}
+
+func stepStrings(plan Plan) string {
+	steps := make([]string, len(plan.Steps))
+	for i, step := range plan.Steps {
+		steps[i] = step.String()
+	}
+
+	return strings.Join(steps, "; ")
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "unlisted devices left alone without selector",
+			want: "update AA02 (tags); activate AA02; create DD01; activate DD01",
+		},
+		{
+			name: "manage all",
+			opts: Options{ManageAll: true},
+			want: "update AA02 (tags); activate AA02; create DD01; activate DD01; deactivate BB01; deactivate CC01",
+		},
+		{
+			name: "selector",
+			opts: Options{Selector: &gonlt.DeviceFilter{Tags: []string{"fleet"}}},
+			want: "update AA02 (tags); activate AA02; create DD01; activate DD01; deactivate BB01",
+		},
+		{
+			name: "selector with prune",
+			opts: Options{Selector: &gonlt.DeviceFilter{Tags: []string{"fleet"}}, Prune: true},
+			want: "update AA02 (tags); activate AA02; create DD01; activate DD01; delete BB01",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := NewSyncer(&fakeDevices{}, tt.opts).Compute(desired(), account())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if got := stepStrings(plan); got != tt.want {
+				t.Errorf("plan = %s\nwant   %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeValidation(t *testing.T) {
+	s := NewSyncer(&fakeDevices{}, Options{ManageAll: true})
+
+	tests := []struct {
+		name    string
+		desired []nlttypes.DeviceCreateRequest
+	}{
+		{"nil", nil},
+		{"empty", []nlttypes.DeviceCreateRequest{}},
+		{"missing eui", []nlttypes.DeviceCreateRequest{{DevEui: " "}}},
+		{"duplicate eui", []nlttypes.DeviceCreateRequest{{DevEui: "AA01"}, {DevEui: "aa01"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Compute(tt.desired, account()); !errors.Is(err, gonlt.ErrValidation) {
+				t.Errorf("got %v, want ErrValidation", err)
+			}
+		})
+	}
+}
+
+func TestComputeAllowEmpty(t *testing.T) {
+	s := NewSyncer(&fakeDevices{}, Options{Selector: &gonlt.DeviceFilter{Tags: []string{"lab"}}, AllowEmpty: true})
+
+	plan, err := s.Compute(nil, account())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := stepStrings(plan); got != "deactivate CC01" {
+		t.Errorf("plan = %s", got)
+	}
+}
+
+func TestApplyReportsPerDevice(t *testing.T) {
+	devices := &fakeDevices{
+		devices: account(),
+		fail:    map[string]error{"update AA02": errors.New("boom")},
+	}
+
+	s := NewSyncer(devices, Options{Selector: &gonlt.DeviceFilter{Tags: []string{"fleet"}}})
+
+	_, report, err := s.Sync(context.Background(), desired())
+
+	var errs gonlt.DeviceErrors
+	if !errors.As(err, &errs) || len(errs) != 1 || errs["AA02"] == nil {
+		t.Fatalf("error = %v, want a failure of AA02 only", err)
+	}
+
+	failed := report.Failed()
+	if len(failed) != 2 || failed[0].Action != Update || !errors.Is(failed[1].Err, ErrSkipped) {
+		t.Errorf("failed = %+v", failed)
+	}
+
+	for _, call := range devices.calls {
+		if call == "activate AA02" {
+			t.Error("activate AA02 ran after its update failed")
+		}
+	}
+
+	if len(devices.calls) != 4 {
+		t.Errorf("calls = %v, want update AA02, create and activate DD01 and deactivate BB01", devices.calls)
+	}
+}
+
+func TestSyncConverges(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"unlisted devices left alone", Options{}},
+		{"manage all", Options{ManageAll: true}},
+		{"selector with prune", Options{Selector: &gonlt.DeviceFilter{Tags: []string{"fleet"}}, Prune: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			devices := &fakeDevices{devices: account()}
+			s := NewSyncer(devices, tt.opts)
+
+			if _, _, err := s.Sync(context.Background(), desired()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			plan, err := s.Plan(context.Background(), desired())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !plan.Empty() {
+				t.Errorf("second plan = %s, want none", stepStrings(plan))
+			}
+		})
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	devices := &fakeDevices{devices: account()}
+
+	var out bytes.Buffer
+
+	s := NewSyncer(devices, Options{DryRun: true, Output: &out})
+
+	if _, _, err := s.Sync(context.Background(), desired()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(devices.calls) != 0 {
+		t.Errorf("dry run changed devices: %v", devices.calls)
+	}
+
+	if !strings.Contains(out.String(), "create DD01") || !strings.Contains(out.String(), "1 to create, 1 to update") {
+		t.Errorf("output = %q", out.String())
+	}
+}
@@ -0,0 +1,117 @@
+package fleet
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/douglaszuqueto/gonlt/internal/strs"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// Action is the change applied to a device
+type Action string
+
+const (
+	Create     Action = "create"
+	Update     Action = "update"
+	Activate   Action = "activate"
+	Deactivate Action = "deactivate"
+	Delete     Action = "delete"
+)
+
+// Step is an action on a device
+type Step struct {
+	Action Action
+	DevEui string
+
+	// Changes lists the fields changed by an update
+	Changes []string
+
+	// Device is the desired device of create and update steps
+	Device *nlttypes.DeviceCreateRequest
+}
+
+func (s Step) String() string {
+	if len(s.Changes) > 0 {
+		return fmt.Sprintf("%s %s (%s)", s.Action, s.DevEui, strings.Join(s.Changes, ", "))
+	}
+
+	return fmt.Sprintf("%s %s", s.Action, s.DevEui)
+}
+
+// Plan is the ordered list of steps reconciling the fleet. Steps of the same
+// device are applied in order.
+type Plan struct {
+	Steps []Step
+}
+
+// Empty reports whether the fleet is already in the desired state
+func (p Plan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Count returns the number of steps of each action
+func (p Plan) Count() map[Action]int {
+	count := map[Action]int{}
+
+	for _, step := range p.Steps {
+		count[step.Action]++
+	}
+
+	return count
+}
+
+// Print writes the plan one step per line, for dry runs
+func (p Plan) Print(w io.Writer) error {
+	if p.Empty() {
+		_, err := fmt.Fprintln(w, "fleet is up to date")
+		return err
+	}
+
+	for _, step := range p.Steps {
+		if _, err := fmt.Fprintln(w, step); err != nil {
+			return err
+		}
+	}
+
+	count := p.Count()
+
+	_, err := fmt.Fprintf(w, "%d to create, %d to update, %d to activate, %d to deactivate, %d to delete\n",
+		count[Create], count[Update], count[Activate], count[Deactivate], count[Delete])
+
+	return err
+}
+
+// diffDevice lists the fields of the current device that differ from the
+// desired one. Keys the API doesn't return are only compared when it does.
+func diffDevice(want nlttypes.DeviceCreateRequest, have nlttypes.Device) []string {
+	var changes []string
+
+	diff := func(field string, changed bool) {
+		if changed {
+			changes = append(changes, field)
+		}
+	}
+
+	diff("tags", !strs.Same(want.Tags, have.Tags))
+	diff("activation", !strings.EqualFold(want.Activation, have.Activation))
+	diff("adr", want.Adr.Mode != have.Adr.Mode)
+	diff("app_eui", !strings.EqualFold(want.AppEui, have.AppEui))
+	diff("app_key", have.AppKey != "" && !strings.EqualFold(want.AppKey, have.AppKey))
+	diff("appskey", have.Appskey != "" && !strings.EqualFold(want.Appskey, have.Appskey))
+	diff("band", want.Band != have.Band)
+	diff("counters_size", want.CountersSize != have.CountersSize)
+	diff("dev_addr", have.DevAddr != "" && !strings.EqualFold(want.DevAddr, have.DevAddr))
+	diff("dev_class", !strings.EqualFold(want.DevClass, have.DevClass))
+	diff("encryption", want.Encryption != have.Encryption)
+	diff("nwkskey", have.Nwkskey != "" && !strings.EqualFold(want.Nwkskey, have.Nwkskey))
+	diff("rx1", want.Rx1.Delay != have.Rx1.Delay)
+	diff("strict_counter", want.StrictCounter != have.StrictCounter)
+	diff("device_type", want.DeviceType != have.DeviceType)
+	diff("contract_id", want.ContractID != have.ContractID)
+	diff("block_downlink", want.BlockDownlink != have.BlockDownlink)
+	diff("block_uplink", want.BlockUplink != have.BlockUplink)
+
+	return changes
+}
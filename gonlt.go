@@ -2,24 +2,27 @@ package gonlt
 
 import (
 	"context"
-	"fmt"
-	"log"
+	"sync"
 	"time"
 
 	"github.com/douglaszuqueto/gonlt/credentials"
 	"github.com/vingarcia/krest"
 )
 
-const (
-	baseURL = "https://lora.nlt-iot.com"
-)
-
 type Client struct {
 	ctx    context.Context
 	cancel context.CancelFunc
 
-	creds credentials.Credentials
-	rest  krest.Client
+	creds  credentials.Credentials
+	tokens *credentials.TokenStore
+	rest   krest.Client
+	cfg    *config
+
+	// loginMu serializes logins so concurrent 401s trigger a single one
+	loginMu sync.Mutex
+
+	expiryMu sync.RWMutex
+	expiry   time.Time
 
 	// Services
 	Auth       AuthServiceOp
@@ -27,31 +30,39 @@ type Client struct {
 	Connection ConnectionServiceOp
 	Device     DeviceServiceOp
 	Message    MessageServiceOp
+	Downlink   DownlinkServiceOp
 }
 
-func NewClient(creds credentials.Credentials) (*Client, error) {
+// NewClient creates a new NLT client, the behavior of the client
+// can be customized with the given options
+func NewClient(creds credentials.Credentials, opts ...Option) (*Client, error) {
 	if err := creds.Validate(); err != nil {
 		return nil, err
 	}
 
-	rest := krest.New(10 * time.Second)
-
 	ctx, cancel := context.WithCancel(context.Background())
 
 	client := &Client{
 		ctx:    ctx,
 		cancel: cancel,
 
-		creds: creds,
-		rest:  rest,
-
-		Auth:       NewAuthService(rest, &creds),
-		Tag:        NewTagsService(rest, &creds),
-		Connection: NewConnectionService(rest, &creds),
-		Device:     NewDeviceService(rest, &creds),
-		Message:    NewMessageService(rest, &creds),
+		creds:  creds,
+		tokens: credentials.NewTokenStore(creds.Token),
+		cfg:    newConfig(opts...),
 	}
 
+	// every request goes through the reauth middleware, so a 401 caused by an
+	// expired token is transparently recovered by logging in again
+	rest := newRest(client.cfg, client.reauthMiddleware)
+
+	client.rest = rest
+	client.Auth = newAuthService(rest, client.tokens, client.cfg)
+	client.Tag = newTagsService(rest, client.tokens, client.cfg)
+	client.Connection = newConnectionService(rest, client.tokens, client.cfg)
+	client.Device = newDeviceService(rest, client.tokens, client.cfg)
+	client.Message = newMessageService(rest, client.tokens, client.cfg)
+	client.Downlink = newDownlinkService(rest, client.tokens, client.cfg)
+
 	if err := client.autoLogin(); err != nil {
 		return nil, err
 	}
@@ -59,38 +70,20 @@ func NewClient(creds credentials.Credentials) (*Client, error) {
 	return client, nil
 }
 
-// autoLogin will try to login if the credentials has the AutoLogin flag set to true
+// autoLogin will try to login if the credentials has the AutoLogin flag set to true,
+// a token still valid in the token cache is used instead
 func (c *Client) autoLogin() error {
 	if !c.creds.AutoLogin {
 		return nil
 	}
 
-	_, err := c.Auth.Login(context.Background(), c.creds.Email, c.creds.Passwd)
-	if err != nil {
-		return err
+	if !c.restoreToken() {
+		if err := c.login(c.ctx); err != nil {
+			return err
+		}
 	}
 
-	go func() {
-		ticker := time.NewTicker(10 * time.Minute)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-c.ctx.Done():
-				log.Println("gonlt: client stopping")
-				return
-			case <-ticker.C:
-				log.Println("gonlt: trying to login")
-
-				_, err := c.Auth.Login(context.Background(), c.creds.Email, c.creds.Passwd)
-				if err != nil {
-					log.Println(err)
-				}
-
-				log.Println("gonlt: token refreshed")
-			}
-		}
-	}()
+	go c.refreshLoop()
 
 	return nil
 }
@@ -99,10 +92,5 @@ func (c *Client) autoLogin() error {
 func (c *Client) Stop() {
 	c.cancel()
 
-	log.Println("gonlt: client stopped")
-}
-
-// build endpoint
-func buildEndpoint(endpoint string) string {
-	return fmt.Sprintf("%s/%s", baseURL, endpoint)
+	c.cfg.logger.Printf("gonlt: client stopped")
 }
@@ -0,0 +1,27 @@
+// Package strs holds string list helpers shared by the gonlt packages
+package strs
+
+// Same reports whether two lists hold the same strings ignoring order,
+// nil and empty are equal
+func Same(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	count := map[string]int{}
+	for _, s := range a {
+		count[s]++
+	}
+
+	for _, s := range b {
+		count[s]--
+	}
+
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,25 @@
+package strs
+
+import "testing"
+
+func TestSame(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want bool
+	}{
+		{name: "nil and empty", a: nil, b: []string{}, want: true},
+		{name: "order ignored", a: []string{"a", "b"}, b: []string{"b", "a"}, want: true},
+		{name: "different length", a: []string{"a"}, b: []string{"a", "a"}, want: false},
+		{name: "different counts", a: []string{"a", "a", "b"}, b: []string{"a", "b", "b"}, want: false},
+		{name: "different strings", a: []string{"a"}, b: []string{"b"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Same(tt.a, tt.b); got != tt.want {
+				t.Errorf("Same(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package lorawan
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+)
+
+// cmac computes the AES-CMAC of msg as defined by RFC 4493
+func cmac(key AES128Key, msg []byte) [16]byte {
+	block, _ := aes.NewCipher(key[:])
+
+	k1, k2 := cmacSubkeys(block)
+
+	n := (len(msg) + aes.BlockSize - 1) / aes.BlockSize
+	complete := n > 0 && len(msg)%aes.BlockSize == 0
+	if n == 0 {
+		n = 1
+	}
+
+	last := make([]byte, aes.BlockSize)
+	copy(last, msg[(n-1)*aes.BlockSize:])
+
+	if complete {
+		xorBytes(last, last, k1[:])
+	} else {
+		last[len(msg)-(n-1)*aes.BlockSize] = 0x80
+		xorBytes(last, last, k2[:])
+	}
+
+	var x [16]byte
+	for i := 0; i < n-1; i++ {
+		xorBytes(x[:], x[:], msg[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(x[:], x[:])
+	}
+
+	xorBytes(x[:], x[:], last)
+	block.Encrypt(x[:], x[:])
+
+	return x
+}
+
+func cmacSubkeys(block cipher.Block) ([16]byte, [16]byte) {
+	var l [16]byte
+	block.Encrypt(l[:], l[:])
+
+	k1 := shiftLeft(l)
+	k2 := shiftLeft(k1)
+
+	return k1, k2
+}
+
+// shiftLeft doubles a block in GF(2^128)
+func shiftLeft(in [16]byte) [16]byte {
+	var out [16]byte
+
+	for i := 0; i < 15; i++ {
+		out[i] = in[i]<<1 | in[i+1]>>7
+	}
+
+	out[15] = in[15] << 1
+
+	if in[0]&0x80 != 0 {
+		out[15] ^= 0x87
+	}
+
+	return out
+}
+
+// xorBytes sets dst[i] = x[i] ^ y[i] for the length of x
+func xorBytes(dst, x, y []byte) {
+	for i := range x {
+		dst[i] = x[i] ^ y[i]
+	}
+}
@@ -0,0 +1,124 @@
+// Package lorawan implements the LoRaWAN 1.0.x payload encryption and
+// message integrity code, to verify the messages reported by the network
+package lorawan
+
+import (
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Direction of a frame
+type Direction byte
+
+const (
+	Uplink   Direction = 0
+	Downlink Direction = 1
+)
+
+// AES128Key is a session key such as the AppSKey or the NwkSKey
+type AES128Key [16]byte
+
+// ParseKey parses a hex encoded key
+func ParseKey(s string) (AES128Key, error) {
+	var key AES128Key
+
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return key, fmt.Errorf("lorawan: invalid key: %w", err)
+	}
+
+	if len(b) != len(key) {
+		return key, fmt.Errorf("lorawan: invalid key size %d, expected %d", len(b), len(key))
+	}
+
+	copy(key[:], b)
+
+	return key, nil
+}
+
+// DevAddr is the device address, most significant byte first as it is displayed
+type DevAddr [4]byte
+
+// ParseDevAddr parses a hex encoded device address
+func ParseDevAddr(s string) (DevAddr, error) {
+	var addr DevAddr
+
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return addr, fmt.Errorf("lorawan: invalid dev addr: %w", err)
+	}
+
+	if len(b) != len(addr) {
+		return addr, fmt.Errorf("lorawan: invalid dev addr size %d, expected %d", len(b), len(addr))
+	}
+
+	copy(addr[:], b)
+
+	return addr, nil
+}
+
+// EncryptFRMPayload encrypts or decrypts the FRMPayload, the operation is
+// symmetric. The AppSKey is used for ports 1-223 and the NwkSKey for port 0.
+func EncryptFRMPayload(key AES128Key, dir Direction, devAddr DevAddr, fCnt uint32, data []byte) []byte {
+	block, _ := aes.NewCipher(key[:])
+
+	out := make([]byte, len(data))
+
+	var a, s [16]byte
+	a[0] = 0x01
+	a[5] = byte(dir)
+	putDevAddr(a[6:10], devAddr)
+	binary.LittleEndian.PutUint32(a[10:14], fCnt)
+
+	for i := 0; i < len(data); i += aes.BlockSize {
+		a[15] = byte(i/aes.BlockSize + 1)
+		block.Encrypt(s[:], a[:])
+
+		end := i + aes.BlockSize
+		if end > len(data) {
+			end = len(data)
+		}
+
+		xorBytes(out[i:end], data[i:end], s[:end-i])
+	}
+
+	return out
+}
+
+// DecryptFRMPayload decrypts the FRMPayload, see EncryptFRMPayload
+func DecryptFRMPayload(key AES128Key, dir Direction, devAddr DevAddr, fCnt uint32, data []byte) []byte {
+	return EncryptFRMPayload(key, dir, devAddr, fCnt, data)
+}
+
+// ComputeMIC computes the MIC of a data frame with the NwkSKey, msg is the
+// PHYPayload without the MIC: MHDR | FHDR | FPort | FRMPayload
+func ComputeMIC(key AES128Key, dir Direction, devAddr DevAddr, fCnt uint32, msg []byte) [4]byte {
+	b0 := make([]byte, 16, 16+len(msg))
+	b0[0] = 0x49
+	b0[5] = byte(dir)
+	putDevAddr(b0[6:10], devAddr)
+	binary.LittleEndian.PutUint32(b0[10:14], fCnt)
+	b0[15] = byte(len(msg))
+
+	mac := cmac(key, append(b0, msg...))
+
+	var mic [4]byte
+	copy(mic[:], mac[:4])
+
+	return mic
+}
+
+// VerifyMIC reports whether mic is the MIC of the data frame
+func VerifyMIC(key AES128Key, dir Direction, devAddr DevAddr, fCnt uint32, msg []byte, mic [4]byte) bool {
+	return ComputeMIC(key, dir, devAddr, fCnt, msg) == mic
+}
+
+// putDevAddr writes the address little endian as it goes on air
+func putDevAddr(dst []byte, devAddr DevAddr) {
+	for i := range devAddr {
+		dst[i] = devAddr[len(devAddr)-1-i]
+	}
+}
@@ -0,0 +1,113 @@
+package lorawan
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func mustKey(t *testing.T, s string) AES128Key {
+	t.Helper()
+
+	key, err := ParseKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+// TestCMAC uses the AES-CMAC vectors of RFC 4493 section 4
+func TestCMAC(t *testing.T) {
+	key := mustKey(t, "2b7e151628aed2a6abf7158809cf4f3c")
+
+	const msg = "6bc1bee22e409f96e93d7e117393172a" +
+		"ae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52ef" +
+		"f69f2445df4f9b17ad2b417be66c3710"
+
+	tests := []struct {
+		name string
+		len  int
+		want string
+	}{
+		{"empty", 0, "bb1d6929e95937287fa37d129b756746"},
+		{"one block", 16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{"partial block", 40, "dfa66747de9ae63030ca32611497c827"},
+		{"four blocks", 64, "51f0bebf7e3b9d92fc49741779363cfe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := cmac(key, mustHex(t, msg)[:tt.len])
+
+			if hex.EncodeToString(got[:]) != tt.want {
+				t.Errorf("cmac = %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestDataFrame checks an uplink of a LoRaWAN 1.0 device:
+// PHYPayload 40 F17DBE49 00 0200 01 95437876 2B11FF0D
+// (MHDR, DevAddr, FCtrl, FCnt, FPort, FRMPayload, MIC)
+func TestDataFrame(t *testing.T) {
+	nwkSKey := mustKey(t, "44024241ed4ce9a68c6a8bc055233fd3")
+	appSKey := mustKey(t, "ec925802ae430ca77fd3dd73cb2cc588")
+
+	devAddr, err := ParseDevAddr("49BE7DF1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	phy := mustHex(t, "40F17DBE4900020001954378762B11FF0D")
+	msg, frmPayload := phy[:len(phy)-4], phy[9:len(phy)-4]
+
+	var mic [4]byte
+	copy(mic[:], phy[len(phy)-4:])
+
+	const fCnt = 2
+
+	if got := DecryptFRMPayload(appSKey, Uplink, devAddr, fCnt, frmPayload); string(got) != "test" {
+		t.Errorf("decrypted %q, want %q", got, "test")
+	}
+
+	if got := EncryptFRMPayload(appSKey, Uplink, devAddr, fCnt, []byte("test")); !bytes.Equal(got, frmPayload) {
+		t.Errorf("encrypted %x, want %x", got, frmPayload)
+	}
+
+	if got := ComputeMIC(nwkSKey, Uplink, devAddr, fCnt, msg); got != mic {
+		t.Errorf("mic = %x, want %x", got, mic)
+	}
+
+	if !VerifyMIC(nwkSKey, Uplink, devAddr, fCnt, msg, mic) {
+		t.Error("VerifyMIC rejected a valid frame")
+	}
+
+	if VerifyMIC(nwkSKey, Downlink, devAddr, fCnt, msg, mic) {
+		t.Error("VerifyMIC accepted the frame for the wrong direction")
+	}
+
+	if VerifyMIC(nwkSKey, Uplink, devAddr, fCnt+1, msg, mic) {
+		t.Error("VerifyMIC accepted the frame for the wrong counter")
+	}
+}
+
+func TestParseKeyErrors(t *testing.T) {
+	for _, s := range []string{"", "zz", "44024241ed4ce9a68c6a8bc055233f"} {
+		if _, err := ParseKey(s); err == nil {
+			t.Errorf("ParseKey(%q) succeeded", s)
+		}
+	}
+}
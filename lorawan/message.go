@@ -0,0 +1,64 @@
+package lorawan
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/douglaszuqueto/gonlt"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// ErrPayloadMismatch is returned when the decrypted payload differs from the reported one
+var ErrPayloadMismatch = errors.New("lorawan: decrypted payload doesn't match")
+
+// DecryptMessage decrypts the encrypted payload of an uplink message with
+// the session keys of the device
+func DecryptMessage(msg nlttypes.Message, device nlttypes.Device) ([]byte, error) {
+	keyHex := device.Appskey
+	if msg.Params.Port == 0 {
+		keyHex = device.Nwkskey
+	}
+
+	key, err := ParseKey(keyHex)
+	if err != nil {
+		return nil, err
+	}
+
+	addrHex := msg.Meta.DeviceAddr
+	if addrHex == "" {
+		addrHex = device.DevAddr
+	}
+
+	devAddr, err := ParseDevAddr(addrHex)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := gonlt.DecodePayload(msg.Params.EncryptedPayload, gonlt.PayloadBase64)
+	if err != nil {
+		return nil, fmt.Errorf("lorawan: encrypted payload: %w", err)
+	}
+
+	return DecryptFRMPayload(key, Uplink, devAddr, uint32(msg.Params.CounterUp), encrypted), nil
+}
+
+// VerifyMessage checks that the payload of the message is the decryption of its
+// encrypted payload, which proves the network used the device keys
+func VerifyMessage(msg nlttypes.Message, device nlttypes.Device) error {
+	decrypted, err := DecryptMessage(msg, device)
+	if err != nil {
+		return err
+	}
+
+	payload, err := gonlt.DecodePayload(msg.Params.Payload, gonlt.PayloadBase64)
+	if err != nil {
+		return fmt.Errorf("lorawan: payload: %w", err)
+	}
+
+	if !bytes.Equal(decrypted, payload) {
+		return ErrPayloadMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,195 @@
+package gonlt
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+const (
+	defaultHistoryWindow      = 24 * time.Hour
+	defaultHistoryConcurrency = 4
+)
+
+// HistoryOptions configures MessageService.History, zero values use the defaults
+type HistoryOptions struct {
+	// Type of the messages, all types when empty
+	Type nlttypes.MessageType
+
+	// Window is the time range fetched by each request, defaults to 24h
+	Window time.Duration
+
+	// Concurrency is the maximum number of windows fetched or buffered at once, defaults to 4
+	Concurrency int
+
+	// Buffer is the capacity of the returned message channel
+	Buffer int
+
+	// Location the dates are converted to before being sent, defaults to UTC
+	Location *time.Location
+}
+
+func (o HistoryOptions) withDefaults() HistoryOptions {
+	if o.Window <= 0 {
+		o.Window = defaultHistoryWindow
+	}
+
+	if o.Window < time.Minute {
+		o.Window = time.Minute
+	}
+
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultHistoryConcurrency
+	}
+
+	return o
+}
+
+type historyWindow struct {
+	start time.Time
+	end   time.Time
+}
+
+type historyResult struct {
+	messages []nlttypes.Message
+	err      error
+}
+
+// History fetches the device messages between start and end splitting the
+// range in windows fetched in parallel. Messages are delivered sorted by
+// time without duplicates. The error channel receives at most one error,
+// both channels are closed when the history is over.
+func (s MessageServiceOp) History(ctx context.Context, deviceEui string, start, end time.Time, opts HistoryOptions) (<-chan nlttypes.Message, <-chan error) {
+	opts = opts.withDefaults()
+
+	out := make(chan nlttypes.Message, opts.Buffer)
+	errc := make(chan error, 1)
+
+	if !end.After(start) {
+		close(out)
+		errc <- fmt.Errorf("%w: end date %s is not after start date %s", ErrValidation, end, start)
+		close(errc)
+
+		return out, errc
+	}
+
+	windows := splitHistory(start, end, opts.Window)
+
+	results := make([]chan historyResult, len(windows))
+	for i := range results {
+		results[i] = make(chan historyResult, 1)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	// sem bounds the windows being fetched or waiting to be delivered
+	sem := make(chan struct{}, opts.Concurrency)
+
+	go func() {
+		for i, w := range windows {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+
+			go func(i int, w historyWindow) {
+				messages, err := s.List(ctx, deviceEui, MessageFilter{
+					Type:      opts.Type,
+					StartDate: w.start.Truncate(time.Minute),
+					EndDate:   w.end.Add(time.Minute - 1).Truncate(time.Minute),
+					Location:  opts.Location,
+				})
+				if err != nil {
+					results[i] <- historyResult{err: err}
+					return
+				}
+
+				results[i] <- historyResult{messages: messages.Messages}
+			}(i, w)
+		}
+	}()
+
+	go func() {
+		defer close(errc)
+		defer close(out)
+		defer cancel()
+
+		seen := map[string]time.Time{}
+
+		for i, w := range windows {
+			var result historyResult
+
+			select {
+			case result = <-results[i]:
+			case <-ctx.Done():
+				errc <- ctx.Err()
+				return
+			}
+
+			<-sem
+
+			if result.err != nil {
+				errc <- result.err
+				return
+			}
+
+			sort.SliceStable(result.messages, func(a, b int) bool {
+				return messageTime(result.messages[a]).Before(messageTime(result.messages[b]))
+			})
+
+			for _, msg := range result.messages {
+				at := messageTime(msg)
+				if at.Before(w.start) || !at.Before(w.end) {
+					continue
+				}
+
+				key := messageKey(msg)
+				if _, ok := seen[key]; ok {
+					continue
+				}
+
+				seen[key] = at
+
+				select {
+				case out <- msg:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+
+			// only messages close to the next window may show up again
+			for key, at := range seen {
+				if at.Before(w.end.Add(-2 * time.Minute)) {
+					delete(seen, key)
+				}
+			}
+		}
+	}()
+
+	return out, errc
+}
+
+// splitHistory splits [start, end) in consecutive windows
+func splitHistory(start, end time.Time, window time.Duration) []historyWindow {
+	var windows []historyWindow
+
+	for t := start; t.Before(end); t = t.Add(window) {
+		w := historyWindow{
+			start: t,
+			end:   t.Add(window),
+		}
+
+		if w.end.After(end) {
+			w.end = end
+		}
+
+		windows = append(windows, w)
+	}
+
+	return windows
+}
@@ -0,0 +1,291 @@
+package gonlt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/douglaszuqueto/gonlt/credentials"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+	"github.com/vingarcia/krest"
+)
+
+// historyStart is the start of the histories fetched by the tests
+const historyStart = 1704067200.0
+
+// historyAPI serves the messages inside the requested dates, delay and fail
+// are called with the start date of each request
+type historyAPI struct {
+	messages []nlttypes.Message
+	delay    func(start time.Time) time.Duration
+	fail     func(start time.Time) bool
+
+	mu        sync.Mutex
+	active    int
+	maxActive int
+}
+
+func (a *historyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start, err := time.Parse(messageDateLayout, r.URL.Query().Get("initial_date"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	end, err := time.Parse(messageDateLayout, r.URL.Query().Get("final_date"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	a.active++
+	if a.active > a.maxActive {
+		a.maxActive = a.active
+	}
+	a.mu.Unlock()
+
+	defer func() {
+		a.mu.Lock()
+		a.active--
+		a.mu.Unlock()
+	}()
+
+	if a.delay != nil {
+		select {
+		case <-time.After(a.delay(start)):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if a.fail != nil && a.fail(start) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// the final date is inclusive up to the minute
+	var body nlttypes.Messages
+	for _, msg := range a.messages {
+		at := messageTime(msg)
+		if !at.Before(start) && at.Before(end.Add(time.Minute)) {
+			body.Messages = append(body.Messages, msg)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func (a *historyAPI) stats() (active, maxActive int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.active, a.maxActive
+}
+
+func newHistoryAPI(t *testing.T, api *historyAPI) MessageServiceOp {
+	t.Helper()
+
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+
+	return NewMessageService(krest.New(defaultTimeout), credentials.NewTokenStore("token"),
+		WithBaseURL(srv.URL), WithMaxRetries(1))
+}
+
+// historyMessage is an uplink sent offset after historyStart
+func historyMessage(hash string, offset time.Duration) nlttypes.Message {
+	return testMessage(nlttypes.MessageUplink, historyStart+offset.Seconds(), func(m *nlttypes.Message) {
+		m.Meta.PacketHash = hash
+	})
+}
+
+func collectHistory(out <-chan nlttypes.Message, errc <-chan error) ([]nlttypes.Message, error) {
+	var messages []nlttypes.Message
+	for msg := range out {
+		messages = append(messages, msg)
+	}
+
+	return messages, <-errc
+}
+
+func hashes(messages []nlttypes.Message) []string {
+	var hashes []string
+	for _, msg := range messages {
+		hashes = append(hashes, msg.Meta.PacketHash)
+	}
+
+	return hashes
+}
+
+func TestHistoryOrdersWindows(t *testing.T) {
+	var messages []nlttypes.Message
+	var want []string
+
+	// the server returns every window sorted from the newest message
+	for i := 15; i >= 0; i-- {
+		messages = append(messages, historyMessage(fmt.Sprintf("m%02d", i), time.Duration(i)*15*time.Minute))
+	}
+	for i := 0; i < 16; i++ {
+		want = append(want, fmt.Sprintf("m%02d", i))
+	}
+
+	start := unixTime(historyStart)
+
+	// the last window completes first
+	api := &historyAPI{
+		messages: messages,
+		delay: func(at time.Time) time.Duration {
+			return (4 - at.Sub(start)/time.Hour) * 30 * time.Millisecond
+		},
+	}
+	s := newHistoryAPI(t, api)
+
+	got, err := collectHistory(s.History(context.Background(), "AA01", start, start.Add(4*time.Hour), HistoryOptions{
+		Window:      time.Hour,
+		Concurrency: 4,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(hashes(got), ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", hashes(got), want)
+	}
+
+	if _, maxActive := api.stats(); maxActive > 4 {
+		t.Errorf("%d windows fetched at once, want at most 4", maxActive)
+	}
+}
+
+func TestHistoryDedupAtWindowBoundary(t *testing.T) {
+	api := &historyAPI{
+		messages: []nlttypes.Message{
+			historyMessage("a", 30*time.Minute),
+			historyMessage("b", time.Hour-500*time.Millisecond),
+			// the same packet reported again by a later gateway
+			historyMessage("b", time.Hour+500*time.Millisecond),
+			historyMessage("c", 90*time.Minute),
+		},
+	}
+	s := newHistoryAPI(t, api)
+
+	start := unixTime(historyStart)
+
+	got, err := collectHistory(s.History(context.Background(), "AA01", start, start.Add(2*time.Hour), HistoryOptions{
+		Window: time.Hour,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "a,b,c"; strings.Join(hashes(got), ",") != want {
+		t.Fatalf("got %v, want %s", hashes(got), want)
+	}
+
+	if at, want := messageTime(got[1]), start.Add(time.Hour-500*time.Millisecond); !at.Equal(want) {
+		t.Errorf("b: got the copy at %s, want the first one at %s", at, want)
+	}
+}
+
+func TestHistoryStopsOnWindowError(t *testing.T) {
+	start := unixTime(historyStart)
+
+	api := &historyAPI{
+		messages: []nlttypes.Message{
+			historyMessage("a", 30*time.Minute),
+			historyMessage("b", 90*time.Minute),
+			historyMessage("c", 150*time.Minute),
+		},
+		delay: func(at time.Time) time.Duration {
+			// windows after the failing one hang until canceled
+			if at.Sub(start) >= 2*time.Hour {
+				return time.Hour
+			}
+
+			return 0
+		},
+		fail: func(at time.Time) bool {
+			return at.Sub(start) == time.Hour
+		},
+	}
+	s := newHistoryAPI(t, api)
+
+	got, err := collectHistory(s.History(context.Background(), "AA01", start, start.Add(6*time.Hour), HistoryOptions{
+		Window:      time.Hour,
+		Concurrency: 4,
+	}))
+	if !errors.Is(err, ErrValidation) {
+		t.Fatalf("got error %v, want %v", err, ErrValidation)
+	}
+
+	if want := "a"; strings.Join(hashes(got), ",") != want {
+		t.Errorf("got %v, want %s", hashes(got), want)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		active, _ := api.stats()
+		leaked := historyGoroutines()
+
+		if active == 0 && leaked == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("%d requests still running and %d history goroutines left", active, leaked)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHistorySequential(t *testing.T) {
+	var messages []nlttypes.Message
+	for i := 0; i < 6; i++ {
+		messages = append(messages, historyMessage(fmt.Sprintf("m%d", i), time.Duration(i)*30*time.Minute))
+	}
+
+	api := &historyAPI{
+		messages: messages,
+		delay: func(time.Time) time.Duration {
+			return 5 * time.Millisecond
+		},
+	}
+	s := newHistoryAPI(t, api)
+
+	start := unixTime(historyStart)
+
+	got, err := collectHistory(s.History(context.Background(), "AA01", start, start.Add(3*time.Hour), HistoryOptions{
+		Window:      time.Hour,
+		Concurrency: 1,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "m0,m1,m2,m3,m4,m5"; strings.Join(hashes(got), ",") != want {
+		t.Errorf("got %v, want %s", hashes(got), want)
+	}
+
+	if _, maxActive := api.stats(); maxActive != 1 {
+		t.Errorf("%d windows fetched at once, want 1", maxActive)
+	}
+}
+
+// historyGoroutines counts the goroutines started by History still running
+func historyGoroutines() int {
+	buf := make([]byte, 1<<20)
+	buf = buf[:runtime.Stack(buf, true)]
+
+	return strings.Count(string(buf), "MessageServiceOp.History.func")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
 	"time"
 
@@ -14,60 +15,112 @@ import (
 
 type MessageService interface {
 	List(ctx context.Context, deviceEui string, filter MessageFilter) (*nlttypes.Messages, error)
+	Watch(ctx context.Context, deviceEui string, opts WatchOptions) <-chan nlttypes.Message
+	WatchMany(ctx context.Context, deviceEuis []string, opts WatchOptions) <-chan nlttypes.Message
+	History(ctx context.Context, deviceEui string, start, end time.Time, opts HistoryOptions) (<-chan nlttypes.Message, <-chan error)
 }
 
 type MessageServiceOp struct {
-	rest  krest.Client
-	creds *credentials.Credentials
+	rest   krest.Client
+	tokens credentials.TokenSource
+	cfg    *config
 }
 
+// messageDateLayout is the date format of the message filters
+const messageDateLayout = "2006-01-02 15:04"
+
 type MessageFilter struct {
-	Type      string
+	// Type of the messages, all types when empty
+	Type nlttypes.MessageType
+
+	// StartDate and EndDate bound the messages, zero values are not sent
 	StartDate time.Time
 	EndDate   time.Time
+
+	// Location the dates are converted to before being sent, defaults to UTC
+	Location *time.Location
+}
+
+// Validate the filter
+func (s MessageFilter) Validate() error {
+	if s.Type != "" && !s.Type.Valid() {
+		return fmt.Errorf("%w: unknown message type %q", ErrValidation, s.Type)
+	}
+
+	if !s.StartDate.IsZero() && !s.EndDate.IsZero() && s.EndDate.Before(s.StartDate) {
+		return fmt.Errorf("%w: end date %s is before start date %s", ErrValidation, s.EndDate, s.StartDate)
+	}
+
+	return nil
 }
 
 // Build a filter for messages
 func (s MessageFilter) Build() (string, error) {
-	filter := ""
+	if err := s.Validate(); err != nil {
+		return "", err
+	}
 
-	filter += fmt.Sprintf("message_type=%s", s.Type)
-	filter += fmt.Sprintf("&initial_date=%s", s.StartDate.Format("2006-01-02 15:04"))
-	filter += fmt.Sprintf("&final_date=%s", s.EndDate.Format("2006-01-02 15:04"))
+	loc := s.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	query := url.Values{}
 
-	return filter, nil
+	if s.Type != "" {
+		query.Set("message_type", string(s.Type))
+	}
+
+	if !s.StartDate.IsZero() {
+		query.Set("initial_date", s.StartDate.In(loc).Format(messageDateLayout))
+	}
+
+	if !s.EndDate.IsZero() {
+		query.Set("final_date", s.EndDate.In(loc).Format(messageDateLayout))
+	}
+
+	return query.Encode(), nil
 }
 
 var _ MessageService = &MessageServiceOp{}
 
-func NewMessageService(rest krest.Client, creds *credentials.Credentials) MessageServiceOp {
+func NewMessageService(rest krest.Client, tokens credentials.TokenSource, opts ...Option) MessageServiceOp {
+	return newMessageService(rest, tokens, newConfig(opts...))
+}
+
+// newMessageService creates the service sharing the config of a Client
+func newMessageService(rest krest.Client, tokens credentials.TokenSource, cfg *config) MessageServiceOp {
 	return MessageServiceOp{
-		rest:  rest,
-		creds: creds,
+		rest:   rest,
+		tokens: tokens,
+		cfg:    cfg,
 	}
 }
 
 func (s MessageServiceOp) List(ctx context.Context, deviceEui string, filter MessageFilter) (*nlttypes.Messages, error) {
-	filterStr, err := filter.Build()
+	query, err := filter.Build()
 	if err != nil {
 		return nil, err
 	}
 
-	urlQuery, err := url.ParseQuery(filterStr)
+	path := "messages/" + deviceEui
+	if query != "" {
+		path += "?" + query
+	}
+
+	endpoint := s.cfg.endpoint(path)
+
+	headers, err := authHeaders(ctx, s.tokens)
 	if err != nil {
 		return nil, err
 	}
 
-	endpoint := buildEndpoint("messages/" + deviceEui + "?" + urlQuery.Encode())
-
 	resp, err := s.rest.Get(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 	})
 	if err != nil {
-		return nil, err
+		return nil, handleError(http.MethodGet, endpoint, resp, err)
 	}
 
 	var messages nlttypes.Messages
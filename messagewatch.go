@@ -0,0 +1,261 @@
+package gonlt
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+const (
+	defaultWatchInterval    = 10 * time.Second
+	defaultWatchMaxBackoff  = 5 * time.Minute
+	defaultWatchConcurrency = 4
+
+	// watchOverlap is subtracted from the start of every poll since the API
+	// filters messages with minute precision
+	watchOverlap = time.Minute
+)
+
+// WatchOptions configures MessageService.Watch, zero values use the defaults
+type WatchOptions struct {
+	// Type of the messages to watch, all when empty
+	Type nlttypes.MessageType
+
+	// Since is the time of the oldest message delivered, defaults to now
+	Since time.Time
+
+	// Interval between polls of each device, defaults to 10s
+	Interval time.Duration
+
+	// MaxBackoff caps the delay between polls after errors, defaults to 5m
+	MaxBackoff time.Duration
+
+	// Concurrency is the maximum number of devices polled at the same time, defaults to 4
+	Concurrency int
+
+	// Buffer is the capacity of the returned channel
+	Buffer int
+
+	// OnError is called for every failed poll, by default errors are logged
+	OnError func(deviceEui string, err error)
+}
+
+func (o WatchOptions) withDefaults() WatchOptions {
+	if o.Since.IsZero() {
+		o.Since = time.Now()
+	}
+
+	if o.Interval <= 0 {
+		o.Interval = defaultWatchInterval
+	}
+
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultWatchMaxBackoff
+	}
+
+	if o.MaxBackoff < o.Interval {
+		o.MaxBackoff = o.Interval
+	}
+
+	if o.Concurrency <= 0 {
+		o.Concurrency = defaultWatchConcurrency
+	}
+
+	return o
+}
+
+// Watch polls the device messages and delivers every new one on the returned
+// channel, which is closed when the context is canceled
+func (s MessageServiceOp) Watch(ctx context.Context, deviceEui string, opts WatchOptions) <-chan nlttypes.Message {
+	return s.WatchMany(ctx, []string{deviceEui}, opts)
+}
+
+// WatchMany watches several devices, polling at most opts.Concurrency of them at once
+func (s MessageServiceOp) WatchMany(ctx context.Context, deviceEuis []string, opts WatchOptions) <-chan nlttypes.Message {
+	opts = opts.withDefaults()
+
+	if opts.OnError == nil {
+		opts.OnError = func(deviceEui string, err error) {
+			s.cfg.logger.Printf("gonlt: watching %s: %v", deviceEui, err)
+		}
+	}
+
+	out := make(chan nlttypes.Message, opts.Buffer)
+	sem := make(chan struct{}, opts.Concurrency)
+
+	var wg sync.WaitGroup
+
+	for _, deviceEui := range deviceEuis {
+		wg.Add(1)
+
+		go func(deviceEui string) {
+			defer wg.Done()
+
+			w := &watcher{
+				service:   s,
+				deviceEui: deviceEui,
+				opts:      opts,
+				cursor:    opts.Since,
+				seen:      map[string]time.Time{},
+				sem:       sem,
+				out:       out,
+			}
+
+			w.run(ctx)
+		}(deviceEui)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
+
+// watcher polls the messages of a single device
+type watcher struct {
+	service   MessageServiceOp
+	deviceEui string
+	opts      WatchOptions
+
+	// cursor is the time of the newest message delivered
+	cursor time.Time
+
+	// seen holds the keys of the delivered messages newer than the poll window
+	seen map[string]time.Time
+
+	sem chan struct{}
+	out chan<- nlttypes.Message
+}
+
+func (w *watcher) run(ctx context.Context) {
+	delay := time.Duration(0)
+
+	for {
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := w.poll(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			delay = w.opts.Interval
+			continue
+		}
+
+		w.opts.OnError(w.deviceEui, err)
+
+		if errors.Is(err, ErrNotFound) {
+			return
+		}
+
+		delay *= 2
+		if delay < w.opts.Interval {
+			delay = w.opts.Interval
+		}
+
+		if delay > w.opts.MaxBackoff {
+			delay = w.opts.MaxBackoff
+		}
+	}
+}
+
+func (w *watcher) poll(ctx context.Context) error {
+	select {
+	case w.sem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	messages, err := w.service.List(ctx, w.deviceEui, MessageFilter{
+		Type:      w.opts.Type,
+		StartDate: w.cursor.Add(-watchOverlap),
+		EndDate:   time.Now().Add(watchOverlap),
+	})
+
+	<-w.sem
+
+	if err != nil {
+		return err
+	}
+
+	fresh := make([]nlttypes.Message, 0, len(messages.Messages))
+
+	for _, msg := range messages.Messages {
+		at := messageTime(msg)
+		if at.Before(w.cursor.Add(-watchOverlap)) {
+			continue
+		}
+
+		key := messageKey(msg)
+		if _, ok := w.seen[key]; ok {
+			continue
+		}
+
+		if at.Before(w.opts.Since) {
+			continue
+		}
+
+		w.seen[key] = at
+		fresh = append(fresh, msg)
+	}
+
+	sort.SliceStable(fresh, func(i, j int) bool {
+		return messageTime(fresh[i]).Before(messageTime(fresh[j]))
+	})
+
+	for _, msg := range fresh {
+		select {
+		case w.out <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		if at := messageTime(msg); at.After(w.cursor) {
+			w.cursor = at
+		}
+	}
+
+	// forget the messages that can't be returned by the next poll
+	for key, at := range w.seen {
+		if at.Before(w.cursor.Add(-2 * watchOverlap)) {
+			delete(w.seen, key)
+		}
+	}
+
+	return nil
+}
+
+// messageTime returns when the message was received by the network,
+// falling back to the time it was stored
+func messageTime(msg nlttypes.Message) time.Time {
+	if msg.Meta.Time > 0 {
+		return unixTime(msg.Meta.Time)
+	}
+
+	t, _ := parseDeviceTime(msg.InsertTime)
+
+	return t
+}
+
+// messageKey identifies a message to remove duplicates
+func messageKey(msg nlttypes.Message) string {
+	if msg.Meta.PacketHash != "" {
+		return msg.Meta.PacketHash
+	}
+
+	return string(msg.Type) + "/" + msg.Meta.PacketID + "/" + messageTime(msg).String()
+}
@@ -112,12 +112,57 @@ type Params struct {
 
 // Messages
 
+type MessageType string
+
+const (
+	MessageUplink          MessageType = "uplink"
+	MessageDownlink        MessageType = "downlink"
+	MessageDownlinkRequest MessageType = "downlink_request"
+	MessageJoinRequest     MessageType = "join_request"
+	MessageJoinAccept      MessageType = "join_accept"
+	MessageStatus          MessageType = "status"
+	MessageLocation        MessageType = "location"
+	MessageInfo            MessageType = "info"
+	MessageWarning         MessageType = "warning"
+	MessageError           MessageType = "error"
+)
+
+// MessageTypes lists every known message type
+var MessageTypes = []MessageType{
+	MessageUplink,
+	MessageDownlink,
+	MessageDownlinkRequest,
+	MessageJoinRequest,
+	MessageJoinAccept,
+	MessageStatus,
+	MessageLocation,
+	MessageInfo,
+	MessageWarning,
+	MessageError,
+}
+
+// Valid reports whether the message type is known
+func (t MessageType) Valid() bool {
+	for _, known := range MessageTypes {
+		if t == known {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PayloadDecoder decodes the payload of a message, see the decoder package
+type PayloadDecoder interface {
+	DecodeMessage(msg Message) (interface{}, error)
+}
+
 type Messages struct {
 	Messages []Message `json:"messages"`
 }
 
 type Message struct {
-	Type string `json:"type"`
+	Type MessageType `json:"type"`
 	Meta struct {
 		Network     string  `json:"network"`
 		PacketHash  string  `json:"packet_hash"`
@@ -160,12 +205,19 @@ type Message struct {
 			Size  int     `json:"size"`
 		} `json:"radio"`
 		CounterUp        int     `json:"counter_up"`
+		CounterDown      int     `json:"counter_down"`
+		Ack              bool    `json:"ack"`
 		RxTime           float64 `json:"rx_time"`
 		EncryptedPayload string  `json:"encrypted_payload"`
 	} `json:"params"`
 	InsertTime string `json:"insert_time"`
 }
 
+// Decode the message payload with the given decoder
+func (m Message) Decode(decoder PayloadDecoder) (interface{}, error) {
+	return decoder.DecodeMessage(m)
+}
+
 // Connection
 
 type ConnectionResponse struct {
@@ -302,6 +354,31 @@ type DeviceUpdateRequest struct {
 	BlockUplink   bool     `json:"block_uplink"`
 }
 
+// UpdateRequest returns the update request keeping every field of the device
+func (d Device) UpdateRequest() DeviceUpdateRequest {
+	return DeviceUpdateRequest{
+		Tags:          d.Tags,
+		Activation:    d.Activation,
+		Adr:           DevAdr{Mode: d.Adr.Mode},
+		AppEui:        d.AppEui,
+		AppKey:        d.AppKey,
+		Appskey:       d.Appskey,
+		Band:          d.Band,
+		CountersSize:  d.CountersSize,
+		DevAddr:       d.DevAddr,
+		DevClass:      d.DevClass,
+		Encryption:    d.Encryption,
+		Nwkskey:       d.Nwkskey,
+		Rx1:           DevRx1{Delay: d.Rx1.Delay},
+		StrictCounter: d.StrictCounter,
+		DeviceType:    d.DeviceType,
+		ContractID:    d.ContractID,
+		DevEui:        d.DevEui,
+		BlockDownlink: d.BlockDownlink,
+		BlockUplink:   d.BlockUplink,
+	}
+}
+
 type DevAdr struct {
 	Mode string `json:"mode"`
 }
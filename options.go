@@ -0,0 +1,124 @@
+package gonlt
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL    = "https://lora.nlt-iot.com"
+	defaultTimeout    = 10 * time.Second
+	defaultMaxRetries = 3
+)
+
+// Logger is the interface used by the client to report its activity.
+// *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Option configures a Client
+type Option func(*config)
+
+// config holds the settings shared by every service of a Client
+type config struct {
+	baseURL    string
+	timeout    time.Duration
+	maxRetries int
+	httpClient *http.Client
+	transport  http.RoundTripper
+	logger     Logger
+
+	onTokenRefresh func(TokenEvent)
+	tokenCache     TokenCache
+}
+
+func newConfig(opts ...Option) *config {
+	cfg := &config{
+		baseURL:    defaultBaseURL,
+		timeout:    defaultTimeout,
+		maxRetries: defaultMaxRetries,
+		logger:     log.Default(),
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// endpoint builds the full URL for the given API path
+func (c *config) endpoint(path string) string {
+	return fmt.Sprintf("%s/%s", c.baseURL, strings.TrimPrefix(path, "/"))
+}
+
+// WithBaseURL sets the NLT API base URL, e.g. a staging tenant or a local stand-in
+func WithBaseURL(baseURL string) Option {
+	return func(c *config) {
+		c.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithTimeout sets the timeout of each HTTP request
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithMaxRetries sets how many times a request is attempted before giving up
+func WithMaxRetries(maxRetries int) Option {
+	return func(c *config) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// WithHTTPClient sets the *http.Client used to perform the requests.
+// When set, its own Timeout takes precedence over WithTimeout.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *config) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the http.RoundTripper used to perform the requests
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *config) {
+		c.transport = transport
+	}
+}
+
+// WithLogger sets the logger used by the client, nil disables logging
+func WithLogger(logger Logger) Option {
+	return func(c *config) {
+		if logger == nil {
+			logger = nopLogger{}
+		}
+
+		c.logger = logger
+	}
+}
+
+// WithTokenRefreshHandler sets a function called after every login attempt
+// made by the client, successful or not
+func WithTokenRefreshHandler(fn func(TokenEvent)) Option {
+	return func(c *config) {
+		c.onTokenRefresh = fn
+	}
+}
+
+// WithTokenCache sets the cache consulted before logging in, tokens are
+// stored per email and base URL
+func WithTokenCache(cache TokenCache) Option {
+	return func(c *config) {
+		c.tokenCache = cache
+	}
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Printf(string, ...interface{}) {}
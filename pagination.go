@@ -0,0 +1,45 @@
+package gonlt
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+)
+
+// defaultPageSize is the page size used when ListOptions.Limit is not set
+const defaultPageSize = 100
+
+// errStopIteration is returned by Iterate callbacks to stop early
+var errStopIteration = errors.New("stop iteration")
+
+// ListOptions selects the page returned by paginated endpoints
+type ListOptions struct {
+	Offset int
+	Limit  int
+}
+
+func (o ListOptions) limit() int {
+	if o.Limit <= 0 {
+		return defaultPageSize
+	}
+
+	return o.Limit
+}
+
+// Build the pagination query string
+func (o ListOptions) Build() string {
+	query := url.Values{}
+
+	query.Set("offset", strconv.Itoa(o.Offset))
+	query.Set("limit", strconv.Itoa(o.limit()))
+
+	return query.Encode()
+}
+
+// next returns the options of the page following one with n items
+func (o ListOptions) next(n int) ListOptions {
+	return ListOptions{
+		Offset: o.Offset + n,
+		Limit:  o.limit(),
+	}
+}
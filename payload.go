@@ -0,0 +1,172 @@
+package gonlt
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+// Downlink port range, port 0 is reserved for MAC commands and 224+ by the LoRaWAN spec
+const (
+	MinDownlinkPort = 1
+	MaxDownlinkPort = 223
+)
+
+// PayloadEncoding is the wire encoding of nlttypes.DownlinkRequest.Payload
+type PayloadEncoding string
+
+const (
+	PayloadBase64 PayloadEncoding = "base64"
+	PayloadHex    PayloadEncoding = "hex"
+)
+
+var (
+	ErrInvalidPort     = errors.New("gonlt: invalid downlink port")
+	ErrPayloadTooLarge = errors.New("gonlt: payload too large for data rate")
+)
+
+// maxPayloadSizes holds the maximum application payload size (N) per data
+// rate of each band, without repeater and with empty FOpts
+var maxPayloadSizes = map[string]map[int]int{
+	nlttypes.BandName: {
+		0:  51,
+		1:  51,
+		2:  51,
+		3:  115,
+		4:  242,
+		5:  242,
+		6:  242,
+		8:  53,
+		9:  129,
+		10: 242,
+		11: 242,
+		12: 242,
+		13: 242,
+	},
+}
+
+// defaultDownlinkDataRate is the RX2 data rate of LA915-928A, the most
+// conservative rate a downlink may be sent with
+const defaultDownlinkDataRate = 8
+
+// MaxPayloadSize returns the maximum application payload size of the data rate on the band
+func MaxPayloadSize(band string, dataRate int) (int, error) {
+	sizes, ok := maxPayloadSizes[band]
+	if !ok {
+		return 0, fmt.Errorf("gonlt: unknown band %q", band)
+	}
+
+	size, ok := sizes[dataRate]
+	if !ok {
+		return 0, fmt.Errorf("gonlt: unknown data rate DR%d for band %s", dataRate, band)
+	}
+
+	return size, nil
+}
+
+// DownlinkEncoder validates and encodes downlink payloads
+type DownlinkEncoder struct {
+	Encoding PayloadEncoding
+	Band     string
+	DataRate int
+}
+
+// DefaultDownlinkEncoder encodes payloads in base64 and limits them to the
+// size allowed by the RX2 data rate of LA915-928A
+func DefaultDownlinkEncoder() DownlinkEncoder {
+	return DownlinkEncoder{
+		Encoding: PayloadBase64,
+		Band:     nlttypes.BandName,
+		DataRate: defaultDownlinkDataRate,
+	}
+}
+
+// Bytes builds a downlink request carrying the payload
+func (e DownlinkEncoder) Bytes(port int, payload []byte, confirmed bool) (nlttypes.DownlinkRequest, error) {
+	if port < MinDownlinkPort || port > MaxDownlinkPort {
+		return nlttypes.DownlinkRequest{}, fmt.Errorf("%w: %d, must be between %d and %d", ErrInvalidPort, port, MinDownlinkPort, MaxDownlinkPort)
+	}
+
+	maxSize, err := MaxPayloadSize(e.Band, e.DataRate)
+	if err != nil {
+		return nlttypes.DownlinkRequest{}, err
+	}
+
+	if len(payload) > maxSize {
+		return nlttypes.DownlinkRequest{}, fmt.Errorf("%w: %d bytes, DR%d allows %d", ErrPayloadTooLarge, len(payload), e.DataRate, maxSize)
+	}
+
+	encoded, err := EncodePayload(payload, e.Encoding)
+	if err != nil {
+		return nlttypes.DownlinkRequest{}, err
+	}
+
+	return nlttypes.DownlinkRequest{
+		Payload:   encoded,
+		Port:      port,
+		Confirmed: confirmed,
+	}, nil
+}
+
+// Hex builds a downlink request from a hex encoded payload
+func (e DownlinkEncoder) Hex(port int, payload string, confirmed bool) (nlttypes.DownlinkRequest, error) {
+	data, err := DecodePayload(payload, PayloadHex)
+	if err != nil {
+		return nlttypes.DownlinkRequest{}, err
+	}
+
+	return e.Bytes(port, data, confirmed)
+}
+
+// Base64 builds a downlink request from a base64 encoded payload
+func (e DownlinkEncoder) Base64(port int, payload string, confirmed bool) (nlttypes.DownlinkRequest, error) {
+	data, err := DecodePayload(payload, PayloadBase64)
+	if err != nil {
+		return nlttypes.DownlinkRequest{}, err
+	}
+
+	return e.Bytes(port, data, confirmed)
+}
+
+// Struct builds a downlink request from the big endian binary representation
+// of v, which must be a fixed size value as accepted by encoding/binary
+func (e DownlinkEncoder) Struct(port int, v interface{}, confirmed bool) (nlttypes.DownlinkRequest, error) {
+	var buf bytes.Buffer
+
+	err := binary.Write(&buf, binary.BigEndian, v)
+	if err != nil {
+		return nlttypes.DownlinkRequest{}, err
+	}
+
+	return e.Bytes(port, buf.Bytes(), confirmed)
+}
+
+// EncodePayload encodes raw bytes with the given encoding
+func EncodePayload(payload []byte, encoding PayloadEncoding) (string, error) {
+	switch encoding {
+	case PayloadBase64:
+		return base64.StdEncoding.EncodeToString(payload), nil
+	case PayloadHex:
+		return hex.EncodeToString(payload), nil
+	default:
+		return "", fmt.Errorf("gonlt: unknown payload encoding %q", encoding)
+	}
+}
+
+// DecodePayload decodes a payload with the given encoding
+func DecodePayload(payload string, encoding PayloadEncoding) ([]byte, error) {
+	switch encoding {
+	case PayloadBase64:
+		return base64.StdEncoding.DecodeString(payload)
+	case PayloadHex:
+		return hex.DecodeString(strings.TrimPrefix(strings.ToLower(payload), "0x"))
+	default:
+		return nil, fmt.Errorf("gonlt: unknown payload encoding %q", encoding)
+	}
+}
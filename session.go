@@ -0,0 +1,249 @@
+package gonlt
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/douglaszuqueto/gonlt/credentials"
+	"github.com/vingarcia/krest"
+)
+
+const (
+	// refreshInterval is used when the token expiration is unknown
+	refreshInterval = 10 * time.Minute
+
+	// refreshMargin is how long before the expiration the token is refreshed
+	refreshMargin = time.Minute
+	refreshJitter = 30 * time.Second
+
+	minRefreshDelay = 5 * time.Second
+	maxRefreshDelay = 5 * time.Minute
+)
+
+// TokenEvent describes the outcome of a login made by the client
+type TokenEvent struct {
+	// Expiry of the new token, zero when unknown or when the login failed
+	Expiry time.Time
+	Err    error
+}
+
+// TokenExpiry returns the expiration of the current token,
+// zero when there is no token or it has no exp claim
+func (c *Client) TokenExpiry() time.Time {
+	c.expiryMu.RLock()
+	defer c.expiryMu.RUnlock()
+
+	return c.expiry
+}
+
+// login authenticates with the client credentials, only one login runs at a time
+func (c *Client) login(ctx context.Context) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	return c.doLogin(ctx)
+}
+
+// doLogin must be called with loginMu held
+func (c *Client) doLogin(ctx context.Context) error {
+	account, err := c.Auth.Login(ctx, c.creds.Email, c.creds.Passwd)
+
+	var expiry time.Time
+	if err == nil {
+		expiry, _ = tokenExpiry(account.AccessToken)
+	}
+
+	if err == nil {
+		c.setExpiry(expiry)
+		c.storeToken(account.AccessToken)
+	}
+
+	if c.cfg.onTokenRefresh != nil {
+		c.cfg.onTokenRefresh(TokenEvent{
+			Expiry: expiry,
+			Err:    err,
+		})
+	}
+
+	return err
+}
+
+func (c *Client) setExpiry(expiry time.Time) {
+	c.expiryMu.Lock()
+	defer c.expiryMu.Unlock()
+
+	c.expiry = expiry
+}
+
+// restoreToken loads the token from the cache, it reports false when there
+// is no cached token or it is about to expire
+func (c *Client) restoreToken() bool {
+	if c.cfg.tokenCache == nil {
+		return false
+	}
+
+	token, ok := c.cfg.tokenCache.Get(tokenCacheKey(c.creds.Email, c.cfg.baseURL))
+	if !ok {
+		return false
+	}
+
+	expiry, ok := tokenExpiry(token)
+	if ok && time.Until(expiry) < refreshMargin {
+		return false
+	}
+
+	c.tokens.SetToken(token)
+	c.setExpiry(expiry)
+
+	c.cfg.logger.Printf("gonlt: using cached token")
+
+	return true
+}
+
+func (c *Client) storeToken(token string) {
+	if c.cfg.tokenCache == nil {
+		return
+	}
+
+	err := c.cfg.tokenCache.Put(tokenCacheKey(c.creds.Email, c.cfg.baseURL), token)
+	if err != nil {
+		c.cfg.logger.Printf("gonlt: caching token: %v", err)
+	}
+}
+
+func (c *Client) invalidateToken() {
+	if c.cfg.tokenCache == nil {
+		return
+	}
+
+	err := c.cfg.tokenCache.Delete(tokenCacheKey(c.creds.Email, c.cfg.baseURL))
+	if err != nil {
+		c.cfg.logger.Printf("gonlt: invalidating cached token: %v", err)
+	}
+}
+
+// refreshLoop keeps the token fresh until the client is stopped
+func (c *Client) refreshLoop() {
+	failures := 0
+
+	for {
+		timer := time.NewTimer(c.nextRefresh(failures))
+
+		select {
+		case <-c.ctx.Done():
+			timer.Stop()
+			c.cfg.logger.Printf("gonlt: client stopping")
+			return
+		case <-timer.C:
+		}
+
+		c.cfg.logger.Printf("gonlt: trying to login")
+
+		if err := c.login(c.ctx); err != nil {
+			failures++
+			c.cfg.logger.Printf("gonlt: %v", err)
+			continue
+		}
+
+		failures = 0
+		c.cfg.logger.Printf("gonlt: token refreshed")
+	}
+}
+
+// nextRefresh computes how long to wait before the next login, backing off
+// exponentially after failures
+func (c *Client) nextRefresh(failures int) time.Duration {
+	if failures > 0 {
+		delay := minRefreshDelay
+		for i := 1; i < failures && delay < maxRefreshDelay; i++ {
+			delay *= 2
+		}
+
+		if delay > maxRefreshDelay {
+			delay = maxRefreshDelay
+		}
+
+		return delay
+	}
+
+	expiry := c.TokenExpiry()
+	if expiry.IsZero() {
+		return refreshInterval
+	}
+
+	delay := time.Until(expiry) - refreshMargin - time.Duration(rand.Int63n(int64(refreshJitter)))
+	if delay < minRefreshDelay {
+		delay = minRefreshDelay
+	}
+
+	return delay
+}
+
+// relogin authenticates again unless another goroutine already replaced
+// the stale token while we were waiting for the lock
+func (c *Client) relogin(ctx context.Context, staleToken string) error {
+	c.loginMu.Lock()
+	defer c.loginMu.Unlock()
+
+	if token, _ := c.tokens.Token(ctx); token != staleToken {
+		return nil
+	}
+
+	c.cfg.logger.Printf("gonlt: token rejected, logging in again")
+
+	c.invalidateToken()
+
+	return c.doLogin(ctx)
+}
+
+// reauthMiddleware replays an authenticated request once with a fresh token
+// when the API answers 401 and the credentials allow logging in again
+func (c *Client) reauthMiddleware(ctx context.Context, method string, url string, data krest.RequestData, next krest.NextMiddleware) (krest.Response, error) {
+	resp, err := next(ctx, method, url, data)
+	if err == nil || resp.StatusCode != http.StatusUnauthorized || !c.creds.AutoLogin {
+		return resp, err
+	}
+
+	authorization := data.Headers["Authorization"]
+	if authorization == "" {
+		return resp, err
+	}
+
+	staleToken := strings.TrimPrefix(authorization, "Bearer ")
+
+	if loginErr := c.relogin(ctx, staleToken); loginErr != nil {
+		c.cfg.logger.Printf("gonlt: %v", loginErr)
+		return resp, err
+	}
+
+	token, tokenErr := c.tokens.Token(ctx)
+	if tokenErr != nil {
+		return resp, err
+	}
+
+	headers := make(map[string]string, len(data.Headers))
+	for k, v := range data.Headers {
+		headers[k] = v
+	}
+
+	headers["Authorization"] = fmt.Sprintf("Bearer %s", token)
+	data.Headers = headers
+
+	return next(ctx, method, url, data)
+}
+
+// authHeaders builds the headers of an authenticated request
+func authHeaders(ctx context.Context, tokens credentials.TokenSource) (map[string]string, error) {
+	token, err := tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"Authorization": fmt.Sprintf("Bearer %s", token),
+	}, nil
+}
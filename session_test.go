@@ -0,0 +1,145 @@
+package gonlt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/douglaszuqueto/gonlt/credentials"
+)
+
+// authAPI is a stand-in issuing a new token on every login and rejecting
+// every token but the last one issued
+type authAPI struct {
+	mu     sync.Mutex
+	issued int
+	valid  string
+
+	logins   int32
+	requests int32
+}
+
+func (a *authAPI) rotate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.issued++
+	a.valid = fmt.Sprintf("token-%d", a.issued)
+}
+
+func (a *authAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/token" {
+		atomic.AddInt32(&a.logins, 1)
+
+		a.rotate()
+
+		a.mu.Lock()
+		token := a.valid
+		a.mu.Unlock()
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": token})
+		return
+	}
+
+	atomic.AddInt32(&a.requests, 1)
+
+	a.mu.Lock()
+	valid := "Bearer " + a.valid
+	a.mu.Unlock()
+
+	if r.Header.Get("Authorization") != valid {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"detail":"invalid token"}`))
+		return
+	}
+
+	_, _ = w.Write([]byte(`[]`))
+}
+
+func TestConcurrentUnauthorizedLogInOnce(t *testing.T) {
+	api := &authAPI{}
+
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	client, err := NewClient(credentials.Credentials{
+		Email:     "user@example.com",
+		Passwd:    "s3cret",
+		AutoLogin: true,
+	}, WithBaseURL(srv.URL), WithMaxRetries(1), WithLogger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Stop()
+
+	if got := atomic.LoadInt32(&api.logins); got != 1 {
+		t.Fatalf("logins after NewClient = %d, want 1", got)
+	}
+
+	// the server forgets the token the client holds
+	api.rotate()
+
+	const calls = 10
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, calls)
+
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := client.Device.List(context.Background(), ListOptions{})
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("List failed: %v", err)
+		}
+	}
+
+	if got := atomic.LoadInt32(&api.logins); got != 2 {
+		t.Errorf("logins = %d, want 2 (NewClient and a single relogin)", got)
+	}
+
+	if got := atomic.LoadInt32(&api.requests); got < calls+1 || got > 2*calls {
+		t.Errorf("device requests = %d, want between %d and %d", got, calls+1, 2*calls)
+	}
+}
+
+func TestLoginIsNotReplayed(t *testing.T) {
+	var logins int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&logins, 1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(credentials.Credentials{
+		Email:     "user@example.com",
+		Passwd:    "wrong",
+		AutoLogin: true,
+	}, WithBaseURL(srv.URL), WithMaxRetries(1), WithLogger(log.New(io.Discard, "", 0)))
+	if err == nil {
+		t.Fatal("NewClient succeeded with rejected credentials")
+	}
+
+	if got := atomic.LoadInt32(&logins); got != 1 {
+		t.Errorf("POST /token made %d times, want 1", got)
+	}
+}
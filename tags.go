@@ -4,48 +4,99 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/douglaszuqueto/gonlt/credentials"
+	"github.com/douglaszuqueto/gonlt/internal/strs"
 	"github.com/douglaszuqueto/gonlt/nlttypes"
 	"github.com/vingarcia/krest"
 )
 
+// defaultTagConcurrency is the number of devices updated at once by the bulk helpers
+const defaultTagConcurrency = 4
+
 type TagsService interface {
 	// List all tags
 	List(ctx context.Context) ([]nlttypes.Tag, error)
+
+	// FindByName returns the tag with the name
+	FindByName(ctx context.Context, name string) (*nlttypes.Tag, error)
+
+	// Create a tag
+	Create(ctx context.Context, req nlttypes.TagRequest) (*nlttypes.Tag, error)
+
+	// Update a tag
+	Update(ctx context.Context, id int, req nlttypes.TagRequest) (*nlttypes.Tag, error)
+
+	// Delete a tag
+	Delete(ctx context.Context, id int) error
+
+	// Attach adds the tags to every device
+	Attach(ctx context.Context, devEuis []string, tags ...string) error
+
+	// Detach removes the tags from every device
+	Detach(ctx context.Context, devEuis []string, tags ...string) error
 }
 
 type TagsServiceOp struct {
 	rest krest.Client
 
-	// Credentials
-	creds *credentials.Credentials
+	// Token source
+	tokens credentials.TokenSource
+	cfg    *config
 }
 
 var _ TagsService = &TagsServiceOp{}
 
-func NewTagsService(rest krest.Client, creds *credentials.Credentials) TagsServiceOp {
+func NewTagsService(rest krest.Client, tokens credentials.TokenSource, opts ...Option) TagsServiceOp {
+	return newTagsService(rest, tokens, newConfig(opts...))
+}
+
+// newTagsService creates the service sharing the config of a Client
+func newTagsService(rest krest.Client, tokens credentials.TokenSource, cfg *config) TagsServiceOp {
 	return TagsServiceOp{
-		rest:  rest,
-		creds: creds,
+		rest:   rest,
+		tokens: tokens,
+		cfg:    cfg,
+	}
+}
+
+// DeviceErrors maps the EUI of the devices a bulk operation failed on to their error
+type DeviceErrors map[string]error
+
+func (e DeviceErrors) Error() string {
+	euis := make([]string, 0, len(e))
+	for eui := range e {
+		euis = append(euis, eui)
+	}
+
+	sort.Strings(euis)
+
+	msgs := make([]string, len(euis))
+	for i, eui := range euis {
+		msgs[i] = fmt.Sprintf("%s: %v", eui, e[eui])
 	}
+
+	return fmt.Sprintf("%d devices failed: %s", len(e), strings.Join(msgs, "; "))
 }
 
 func (s TagsServiceOp) List(ctx context.Context) ([]nlttypes.Tag, error) {
-	endpoint := buildEndpoint("tags")
+	endpoint := s.cfg.endpoint("tags")
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
 
 	resp, err := s.rest.Get(ctx, endpoint, krest.RequestData{
-		Headers: map[string]string{
-			"Authorization": fmt.Sprintf("Bearer %s", s.creds.Token),
-		},
-		MaxRetries: 3,
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
 	})
 	if err != nil {
-		if resp.StatusCode == 401 {
-			return nil, handleUnauthorizedError(resp.Body)
-		}
-
-		return nil, err
+		return nil, handleError(http.MethodGet, endpoint, resp, err)
 	}
 
 	var tags []nlttypes.Tag
@@ -57,3 +108,205 @@ func (s TagsServiceOp) List(ctx context.Context) ([]nlttypes.Tag, error) {
 
 	return tags, nil
 }
+
+// FindByName returns the tag with the name, looking it up in the listing
+func (s TagsServiceOp) FindByName(ctx context.Context, name string) (*nlttypes.Tag, error) {
+	tags, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tag := range tags {
+		if tag.Name == name {
+			return &tag, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: tag %q", ErrNotFound, name)
+}
+
+// Create a tag
+func (s TagsServiceOp) Create(ctx context.Context, req nlttypes.TagRequest) (*nlttypes.Tag, error) {
+	endpoint := s.cfg.endpoint("tags")
+
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("%w: tag name is required", ErrValidation)
+	}
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.rest.Post(ctx, endpoint, krest.RequestData{
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
+		Body:       req,
+	})
+	if err != nil {
+		return nil, handleError(http.MethodPost, endpoint, resp, err)
+	}
+
+	var tag nlttypes.Tag
+
+	err = json.Unmarshal(resp.Body, &tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// Update a tag
+func (s TagsServiceOp) Update(ctx context.Context, id int, req nlttypes.TagRequest) (*nlttypes.Tag, error) {
+	endpoint := s.cfg.endpoint(fmt.Sprintf("tags/%d", id))
+
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("%w: tag name is required", ErrValidation)
+	}
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.rest.Patch(ctx, endpoint, krest.RequestData{
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
+		Body:       req,
+	})
+	if err != nil {
+		return nil, handleError(http.MethodPatch, endpoint, resp, err)
+	}
+
+	var tag nlttypes.Tag
+
+	err = json.Unmarshal(resp.Body, &tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// Delete a tag, any 2xx status is a success
+func (s TagsServiceOp) Delete(ctx context.Context, id int) error {
+	endpoint := s.cfg.endpoint(fmt.Sprintf("tags/%d", id))
+
+	headers, err := authHeaders(ctx, s.tokens)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.rest.Delete(ctx, endpoint, krest.RequestData{
+		Headers:    headers,
+		MaxRetries: s.cfg.maxRetries,
+	})
+	if err != nil {
+		return handleError(http.MethodDelete, endpoint, resp, err)
+	}
+
+	return nil
+}
+
+// Attach adds the tags to every device, devices already tagged are not
+// updated. Failures are reported as DeviceErrors.
+func (s TagsServiceOp) Attach(ctx context.Context, devEuis []string, tags ...string) error {
+	return s.retag(ctx, devEuis, func(current []string) []string {
+		return addTags(current, tags)
+	})
+}
+
+// Detach removes the tags from every device, devices without them are not
+// updated. Failures are reported as DeviceErrors.
+func (s TagsServiceOp) Detach(ctx context.Context, devEuis []string, tags ...string) error {
+	return s.retag(ctx, devEuis, func(current []string) []string {
+		return removeTags(current, tags)
+	})
+}
+
+// retag updates the tags of the devices, at most defaultTagConcurrency at once
+func (s TagsServiceOp) retag(ctx context.Context, devEuis []string, change func([]string) []string) error {
+	devices := DeviceServiceOp{
+		rest:   s.rest,
+		tokens: s.tokens,
+		cfg:    s.cfg,
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = DeviceErrors{}
+	)
+
+	sem := make(chan struct{}, defaultTagConcurrency)
+
+	for _, devEui := range devEuis {
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(devEui string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := retagDevice(ctx, devices, devEui, change); err != nil {
+				mu.Lock()
+				errs[devEui] = err
+				mu.Unlock()
+			}
+		}(devEui)
+	}
+
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func retagDevice(ctx context.Context, devices DeviceServiceOp, devEui string, change func([]string) []string) error {
+	device, err := devices.Find(ctx, devEui)
+	if err != nil {
+		return err
+	}
+
+	tags := change(device.Tags)
+	if strs.Same(tags, device.Tags) {
+		return nil
+	}
+
+	req := device.UpdateRequest()
+	req.Tags = tags
+
+	_, err = devices.Update(ctx, req)
+
+	return err
+}
+
+// addTags returns the tags with the missing ones appended
+func addTags(tags, add []string) []string {
+	out := append([]string{}, tags...)
+
+	for _, tag := range add {
+		if !hasTags(out, []string{tag}) {
+			out = append(out, tag)
+		}
+	}
+
+	return out
+}
+
+// removeTags returns the tags without the removed ones
+func removeTags(tags, remove []string) []string {
+	out := []string{}
+
+	for _, tag := range tags {
+		if !hasTags(remove, []string{tag}) {
+			out = append(out, tag)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,133 @@
+package gonlt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenCache persists tokens between process runs so short lived programs
+// don't need to login every time they start
+type TokenCache interface {
+	// Get returns the token stored for the key, if any
+	Get(key string) (string, bool)
+
+	// Put stores the token for the key
+	Put(key, token string) error
+
+	// Delete removes the token stored for the key
+	Delete(key string) error
+}
+
+// FileTokenCache is a TokenCache backed by a JSON file only readable by its owner
+type FileTokenCache struct {
+	mu   sync.Mutex
+	path string
+}
+
+var _ TokenCache = &FileTokenCache{}
+
+// NewFileTokenCache creates a cache stored at path, the file is created on the first Put
+func NewFileTokenCache(path string) *FileTokenCache {
+	return &FileTokenCache{
+		path: path,
+	}
+}
+
+// DefaultTokenCachePath returns the path of the cache file in the user cache directory
+func DefaultTokenCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "gonlt", "tokens.json"), nil
+}
+
+func (c *FileTokenCache) Get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens, err := c.read()
+	if err != nil {
+		return "", false
+	}
+
+	token, ok := tokens[key]
+
+	return token, ok && token != ""
+}
+
+func (c *FileTokenCache) Put(key, token string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens, err := c.read()
+	if err != nil {
+		tokens = map[string]string{}
+	}
+
+	tokens[key] = token
+
+	return c.write(tokens)
+}
+
+func (c *FileTokenCache) Delete(key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tokens, err := c.read()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	if _, ok := tokens[key]; !ok {
+		return nil
+	}
+
+	delete(tokens, key)
+
+	return c.write(tokens)
+}
+
+func (c *FileTokenCache) read() (map[string]string, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := map[string]string{}
+
+	err = json.Unmarshal(data, &tokens)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// write replaces the cache file atomically
+func (c *FileTokenCache) write(tokens map[string]string) error {
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(c.path, data)
+}
+
+// tokenCacheKey identifies the tokens of an account on an NLT tenant
+func tokenCacheKey(email, baseURL string) string {
+	sum := sha256.Sum256([]byte(email + "\n" + baseURL))
+
+	return hex.EncodeToString(sum[:])
+}
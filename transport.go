@@ -0,0 +1,108 @@
+package gonlt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/vingarcia/krest"
+)
+
+// newRest builds the krest client for the given config
+func newRest(cfg *config, middlewares ...krest.Middleware) krest.Client {
+	rest := krest.New(cfg.timeout, middlewares...)
+
+	httpClient := cfg.httpClient
+	if httpClient == nil && cfg.transport != nil {
+		httpClient = &http.Client{
+			Timeout:   cfg.timeout,
+			Transport: cfg.transport,
+		}
+	}
+
+	if httpClient != nil {
+		rest.AddMiddleware(httpClientMiddleware(httpClient))
+	}
+
+	return rest
+}
+
+// httpClientMiddleware performs the request with the given *http.Client
+// instead of the one krest builds for every request. It must be the last
+// middleware of the chain since it never calls next.
+func httpClientMiddleware(httpClient *http.Client) krest.Middleware {
+	return func(ctx context.Context, method string, url string, data krest.RequestData, _ krest.NextMiddleware) (krest.Response, error) {
+		data.SetDefaultsIfNecessary()
+
+		var payload []byte
+		switch body := data.Body.(type) {
+		case nil:
+		case []byte:
+			payload = body
+		case string:
+			payload = []byte(body)
+		default:
+			var err error
+
+			payload, err = json.Marshal(body)
+			if err != nil {
+				return krest.Response{}, err
+			}
+		}
+
+		var (
+			resp *http.Response
+			err  error
+		)
+
+		krest.Retry(ctx, data.BaseRetryDelay, data.MaxRetryDelay, data.MaxRetries, func() bool {
+			if resp != nil {
+				resp.Body.Close()
+			}
+
+			var body io.Reader
+			if payload != nil {
+				body = bytes.NewReader(payload)
+			}
+
+			var req *http.Request
+			req, err = http.NewRequestWithContext(ctx, method, url, body)
+			if err != nil {
+				return false
+			}
+
+			for k, v := range data.Headers {
+				req.Header.Set(k, v)
+			}
+
+			resp, err = httpClient.Do(req)
+			return data.RetryRule(resp, err)
+		})
+		if err != nil {
+			return krest.Response{}, err
+		}
+		defer resp.Body.Close()
+
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return krest.Response{}, err
+		}
+
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			err = fmt.Errorf(
+				"%s %s: unexpected status code: %d, payload: %s",
+				method, url, resp.StatusCode, string(body),
+			)
+		}
+
+		return krest.Response{
+			ReadCloser: io.NopCloser(bytes.NewReader(body)),
+			Body:       body,
+			Header:     resp.Header,
+			StatusCode: resp.StatusCode,
+		}, err
+	}
+}
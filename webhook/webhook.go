@@ -0,0 +1,320 @@
+// Package webhook receives the messages NLT pushes to the URL of a connection
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/douglaszuqueto/gonlt"
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+const (
+	// DefaultHeader carries the auth header of the connection
+	DefaultHeader = "Authorization"
+
+	// DefaultMaxBodySize limits the size of a pushed request body
+	DefaultMaxBodySize = 1 << 20
+)
+
+// Handler handles a message pushed by NLT
+type Handler interface {
+	HandleMessage(ctx context.Context, msg nlttypes.Message) error
+}
+
+// HandlerFunc adapts a function to the Handler interface
+type HandlerFunc func(ctx context.Context, msg nlttypes.Message) error
+
+func (f HandlerFunc) HandleMessage(ctx context.Context, msg nlttypes.Message) error {
+	return f(ctx, msg)
+}
+
+type route struct {
+	types   []nlttypes.MessageType
+	handler Handler
+}
+
+// Receiver is an http.Handler for the requests of a NLT connection. It
+// checks the auth header of the connection, decodes the pushed messages,
+// drops the ones its filter excludes and dispatches the others to the
+// registered handlers.
+//
+// Requests are answered with 204 when every message was handled, 401 when
+// the auth header doesn't match, 400 when the body is not a message or a
+// list of messages and 500 when a handler fails, so NLT can retry.
+type Receiver struct {
+	mu sync.RWMutex
+
+	// Header carrying the auth header of the connection, defaults to Authorization
+	Header string
+
+	// MaxBodySize limits the request body, defaults to 1MB
+	MaxBodySize int64
+
+	// OnError is called when a handler fails, by default errors are logged
+	OnError func(msg nlttypes.Message, err error)
+
+	// Logger reports handler errors when OnError is nil, defaults to log.Default()
+	Logger gonlt.Logger
+
+	authHeader string
+	filter     nlttypes.Filtermodel
+	routes     []route
+
+	// deviceTags maps a device EUI to its tags, messages don't carry them
+	deviceTags map[string][]string
+}
+
+var _ http.Handler = &Receiver{}
+
+// NewReceiver creates a receiver for the connection and its filter
+func NewReceiver(conn nlttypes.Connectionmodel, filter nlttypes.Filtermodel) *Receiver {
+	return &Receiver{
+		Header:      DefaultHeader,
+		MaxBodySize: DefaultMaxBodySize,
+		Logger:      log.Default(),
+		authHeader:  conn.AuthHeader,
+		filter:      filter,
+		deviceTags:  map[string][]string{},
+	}
+}
+
+// NewReceiverFromData creates a receiver for a connection returned by ConnectionService
+func NewReceiverFromData(data nlttypes.Data) *Receiver {
+	return NewReceiver(data.Connectionmodel, data.Filtermodel)
+}
+
+// Handle registers a handler for the message types, every type when none is given
+func (r *Receiver) Handle(h Handler, types ...nlttypes.MessageType) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.routes = append(r.routes, route{types: types, handler: h})
+}
+
+// HandleFunc registers a function for the message types, every type when none is given
+func (r *Receiver) HandleFunc(fn func(ctx context.Context, msg nlttypes.Message) error, types ...nlttypes.MessageType) {
+	r.Handle(HandlerFunc(fn), types...)
+}
+
+// AddDevices teaches the receiver the tags of each device, required by filters on tags
+func (r *Receiver) AddDevices(devices ...nlttypes.Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, device := range devices {
+		r.deviceTags[device.DevEui] = device.Tags
+	}
+}
+
+// Accept reports whether the filter of the connection lets the message
+// through. Filters on tags only apply to the devices given to AddDevices.
+func (r *Receiver) Accept(msg nlttypes.Message) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	f := r.filter
+
+	if f.IsDisabled {
+		return false
+	}
+
+	if !f.Duplicate && msg.Params.Duplicate {
+		return false
+	}
+
+	if len(f.Types) > 0 && !contains(f.Types, string(msg.Type)) {
+		return false
+	}
+
+	if len(f.Devices) > 0 && !contains(f.Devices, msg.Meta.Device) {
+		return false
+	}
+
+	if len(f.Gateways) > 0 && !contains(f.Gateways, msg.Meta.Gateway) {
+		return false
+	}
+
+	if len(f.Applications) > 0 && !contains(f.Applications, msg.Meta.Application) {
+		return false
+	}
+
+	// NLT already applied the filter, so devices whose tags were never
+	// added are trusted rather than dropped after acknowledging them
+	deviceTags, known := r.deviceTags[msg.Meta.Device]
+
+	if len(f.Tags) > 0 && known {
+		matched := false
+
+		for _, tag := range deviceTags {
+			if contains(f.Tags, tag) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ServeHTTP implements http.Handler
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !r.authorized(req) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	maxBodySize := r.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, "error reading request body", http.StatusBadRequest)
+		return
+	}
+
+	messages, err := parseMessages(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := r.dispatch(req.Context(), messages); err != nil {
+		http.Error(w, "error handling message", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (r *Receiver) authorized(req *http.Request) bool {
+	if r.authHeader == "" {
+		return true
+	}
+
+	header := r.Header
+	if header == "" {
+		header = DefaultHeader
+	}
+
+	got := req.Header.Get(header)
+
+	return subtle.ConstantTimeCompare([]byte(got), []byte(r.authHeader)) == 1
+}
+
+// dispatch hands the accepted messages to their handlers and returns the first error
+func (r *Receiver) dispatch(ctx context.Context, messages []nlttypes.Message) error {
+	r.mu.RLock()
+	routes := make([]route, len(r.routes))
+	copy(routes, r.routes)
+	r.mu.RUnlock()
+
+	var first error
+
+	for _, msg := range messages {
+		if !r.Accept(msg) {
+			continue
+		}
+
+		for _, rt := range routes {
+			if len(rt.types) > 0 && !containsType(rt.types, msg.Type) {
+				continue
+			}
+
+			if err := rt.handler.HandleMessage(ctx, msg); err != nil {
+				r.onError(msg, err)
+
+				if first == nil {
+					first = err
+				}
+			}
+		}
+	}
+
+	return first
+}
+
+func (r *Receiver) onError(msg nlttypes.Message, err error) {
+	if r.OnError != nil {
+		r.OnError(msg, err)
+		return
+	}
+
+	logger := r.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	logger.Printf("webhook: %s message of device %s: %v", msg.Type, msg.Meta.Device, err)
+}
+
+// parseMessages decodes a single message or a list of messages
+func parseMessages(body []byte) ([]nlttypes.Message, error) {
+	for _, b := range body {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			var messages []nlttypes.Message
+			if err := json.Unmarshal(body, &messages); err != nil {
+				return nil, fmt.Errorf("invalid messages: %w", err)
+			}
+
+			return messages, nil
+		default:
+			var msg nlttypes.Message
+			if err := json.Unmarshal(body, &msg); err != nil {
+				return nil, fmt.Errorf("invalid message: %w", err)
+			}
+
+			return []nlttypes.Message{msg}, nil
+		}
+	}
+
+	return nil, errors.New("empty request body")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func containsType(types []nlttypes.MessageType, t nlttypes.MessageType) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+
+	return false
+}
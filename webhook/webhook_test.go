@@ -0,0 +1,144 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/douglaszuqueto/gonlt/nlttypes"
+)
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func post(r *Receiver, auth, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/nlt", strings.NewReader(body))
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestReceiverStatusCodes(t *testing.T) {
+	r := NewReceiver(nlttypes.Connectionmodel{AuthHeader: "secret"}, nlttypes.Filtermodel{})
+	r.Logger = &testLogger{}
+	r.HandleFunc(func(ctx context.Context, msg nlttypes.Message) error {
+		if msg.Meta.Device == "broken" {
+			return errors.New("boom")
+		}
+
+		return nil
+	})
+
+	tests := []struct {
+		name string
+		auth string
+		body string
+		want int
+	}{
+		{"handled", "secret", `{"type":"uplink","meta":{"device":"d1"}}`, http.StatusNoContent},
+		{"list", "secret", `[{"type":"uplink"},{"type":"status"}]`, http.StatusNoContent},
+		{"wrong auth", "nope", `{"type":"uplink"}`, http.StatusUnauthorized},
+		{"missing auth", "", `{"type":"uplink"}`, http.StatusUnauthorized},
+		{"invalid json", "secret", `{"type":`, http.StatusBadRequest},
+		{"empty body", "secret", ``, http.StatusBadRequest},
+		{"handler error", "secret", `{"type":"uplink","meta":{"device":"broken"}}`, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := post(r, tt.auth, tt.body); w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/nlt", nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestReceiverFilter(t *testing.T) {
+	r := NewReceiver(nlttypes.Connectionmodel{}, nlttypes.Filtermodel{
+		Types: []string{"uplink"},
+		Tags:  []string{"farm"},
+	})
+	r.AddDevices(
+		nlttypes.Device{DevEui: "tagged", Tags: []string{"farm"}},
+		nlttypes.Device{DevEui: "other", Tags: []string{"city"}},
+	)
+
+	var handled []string
+	r.HandleFunc(func(ctx context.Context, msg nlttypes.Message) error {
+		handled = append(handled, msg.Meta.Device)
+		return nil
+	})
+
+	body := `[
+		{"type":"uplink","meta":{"device":"tagged"}},
+		{"type":"uplink","meta":{"device":"other"}},
+		{"type":"uplink","meta":{"device":"unknown"}},
+		{"type":"status","meta":{"device":"tagged"}},
+		{"type":"uplink","meta":{"device":"tagged"},"params":{"duplicate":true}}
+	]`
+
+	if w := post(r, "", body); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	want := []string{"tagged", "unknown"}
+	if strings.Join(handled, ",") != strings.Join(want, ",") {
+		t.Errorf("handled %v, want %v", handled, want)
+	}
+}
+
+func TestReceiverUnknownTagsAreHandled(t *testing.T) {
+	r := NewReceiver(nlttypes.Connectionmodel{}, nlttypes.Filtermodel{Tags: []string{"farm"}})
+
+	calls := 0
+	r.HandleFunc(func(ctx context.Context, msg nlttypes.Message) error {
+		calls++
+		return nil
+	})
+
+	if w := post(r, "", `{"type":"uplink","meta":{"device":"d1"}}`); w.Code != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusNoContent)
+	}
+
+	if calls != 1 {
+		t.Errorf("handler called %d times, want 1", calls)
+	}
+}
+
+func TestReceiverLogsHandlerErrors(t *testing.T) {
+	logger := &testLogger{}
+
+	r := NewReceiver(nlttypes.Connectionmodel{}, nlttypes.Filtermodel{})
+	r.Logger = logger
+	r.HandleFunc(func(ctx context.Context, msg nlttypes.Message) error {
+		return errors.New("boom")
+	}, nlttypes.MessageUplink)
+
+	post(r, "", `{"type":"uplink","meta":{"device":"d1"}}`)
+
+	if len(logger.lines) != 1 || !strings.Contains(logger.lines[0], "boom") {
+		t.Errorf("logged %q", logger.lines)
+	}
+}